@@ -0,0 +1,41 @@
+// Command nexus 提供 Nexus 項目的運維工具
+//
+// 用法：
+//
+//	nexus nats <command> [flags] [args]
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `usage: nexus <command> [flags] [args]
+
+commands:
+  nats    inspect and manage JetStream streams and consumers
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "nats":
+		err = runNats(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"go.uber.org/zap"
+
+	"goflare.io/nexus"
+	"goflare.io/nexus/driver"
+)
+
+const natsUsage = `usage: nexus nats [-config path] [-timeout d] <command> [flags] [args]
+
+commands:
+  streams                                list streams
+  stream <stream>                        describe a stream
+  consumers [stream]                     list consumers with pending counts, default is the configured stream
+  consumer <stream> <consumer>           describe a consumer
+  purge [-subject s] [-keep n] -yes <stream>
+                                         purge messages from a stream
+  peek [-count n] <stream> <seq>         print messages starting at a sequence
+  replay -to <subject> [-yes] <stream> <from> [to]
+                                         republish a range of messages to another subject
+`
+
+// 重放的消息攜帶的消息頭，記錄消息的來源
+const (
+	replayStreamHeader   = "Nexus-Replay-Stream"   // 原始 stream
+	replaySequenceHeader = "Nexus-Replay-Sequence" // 原始 stream 序列號
+)
+
+// natsCLI 保存命令共享的連接和配置
+type natsCLI struct {
+	config driver.NatsConfig
+	nc     *nats.Conn
+	js     jetstream.JetStream
+	out    io.Writer
+}
+
+func runNats(args []string) error {
+	fs := flag.NewFlagSet("nats", flag.ContinueOnError)
+	configPath := fs.String("config", nexus.DefaultConfigPath, "path to the nexus config file")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for the whole command")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, natsUsage) }
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("missing command")
+	}
+
+	config, err := nexus.ReadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if config.NATS.URL == "" {
+		return fmt.Errorf("nats.url is not set in %s", *configPath)
+	}
+
+	nc, err := driver.ConnectNats(config.NATS, zap.NewNop(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", config.NATS.URL, err)
+	}
+	defer nc.Close()
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return fmt.Errorf("failed to create jetstream: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	cli := &natsCLI{config: config.NATS, nc: nc, js: js, out: os.Stdout}
+
+	command, rest := fs.Arg(0), fs.Args()[1:]
+	switch command {
+	case "streams":
+		return cli.streams(ctx)
+	case "stream":
+		return cli.stream(ctx, rest)
+	case "consumers":
+		return cli.consumers(ctx, rest)
+	case "consumer":
+		return cli.consumer(ctx, rest)
+	case "purge":
+		return cli.purge(ctx, rest)
+	case "peek":
+		return cli.peek(ctx, rest)
+	case "replay":
+		return cli.replay(ctx, rest)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown nats command %q", command)
+	}
+}
+
+func (c *natsCLI) streams(ctx context.Context) error {
+	lister := c.js.ListStreams(ctx)
+
+	var infos []*jetstream.StreamInfo
+	for info := range lister.Info() {
+		infos = append(infos, info)
+	}
+	if err := lister.Err(); err != nil {
+		return fmt.Errorf("failed to list streams: %w", err)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Config.Name < infos[j].Config.Name })
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSUBJECTS\tSTORAGE\tRETENTION\tMESSAGES\tBYTES\tCONSUMERS\tFIRST SEQ\tLAST SEQ")
+	for _, info := range infos {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\n",
+			info.Config.Name,
+			strings.Join(info.Config.Subjects, ","),
+			info.Config.Storage,
+			info.Config.Retention,
+			info.State.Msgs,
+			info.State.Bytes,
+			info.State.Consumers,
+			info.State.FirstSeq,
+			info.State.LastSeq)
+	}
+	return w.Flush()
+}
+
+func (c *natsCLI) stream(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: nexus nats stream <stream>")
+	}
+
+	stream, err := c.js.Stream(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("failed to get stream %s: %w", args[0], err)
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get stream info: %w", err)
+	}
+	return c.printJSON(info)
+}
+
+func (c *natsCLI) consumers(ctx context.Context, args []string) error {
+	name := c.config.StreamName
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if name == "" {
+		return errors.New("usage: nexus nats consumers <stream>")
+	}
+
+	stream, err := c.js.Stream(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get stream %s: %w", name, err)
+	}
+
+	lister := stream.ListConsumers(ctx)
+	var infos []*jetstream.ConsumerInfo
+	for info := range lister.Info() {
+		infos = append(infos, info)
+	}
+	if err = lister.Err(); err != nil {
+		return fmt.Errorf("failed to list consumers: %w", err)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tFILTER\tPENDING\tACK PENDING\tREDELIVERED\tWAITING\tDELIVERED SEQ\tACK FLOOR")
+	for _, info := range infos {
+		filter := info.Config.FilterSubject
+		if filter == "" {
+			filter = strings.Join(info.Config.FilterSubjects, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\n",
+			info.Name,
+			filter,
+			info.NumPending,
+			info.NumAckPending,
+			info.NumRedelivered,
+			info.NumWaiting,
+			info.Delivered.Stream,
+			info.AckFloor.Stream)
+	}
+	return w.Flush()
+}
+
+func (c *natsCLI) consumer(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: nexus nats consumer <stream> <consumer>")
+	}
+
+	consumer, err := c.js.Consumer(ctx, args[0], args[1])
+	if err != nil {
+		return fmt.Errorf("failed to get consumer %s: %w", args[1], err)
+	}
+	info, err := consumer.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get consumer info: %w", err)
+	}
+	return c.printJSON(info)
+}
+
+func (c *natsCLI) purge(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ContinueOnError)
+	subject := fs.String("subject", "", "only purge messages on this subject (wildcards allowed)")
+	keep := fs.Uint64("keep", 0, "number of most recent messages to keep")
+	yes := fs.Bool("yes", false, "confirm the purge")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: nexus nats purge [-subject s] [-keep n] -yes <stream>")
+	}
+	if !*yes {
+		return errors.New("purge deletes messages permanently, pass -yes to confirm")
+	}
+
+	stream, err := c.js.Stream(ctx, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to get stream %s: %w", fs.Arg(0), err)
+	}
+
+	var opts []jetstream.StreamPurgeOpt
+	if *subject != "" {
+		opts = append(opts, jetstream.WithPurgeSubject(*subject))
+	}
+	if *keep > 0 {
+		opts = append(opts, jetstream.WithPurgeKeep(*keep))
+	}
+
+	before := stream.CachedInfo().State.Msgs
+	if err = stream.Purge(ctx, opts...); err != nil {
+		return fmt.Errorf("failed to purge stream: %w", err)
+	}
+
+	info, err := stream.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get stream info: %w", err)
+	}
+	fmt.Fprintf(c.out, "purged %d messages from %s, %d remaining\n",
+		before-min(before, info.State.Msgs), fs.Arg(0), info.State.Msgs)
+	return nil
+}
+
+func (c *natsCLI) peek(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("peek", flag.ContinueOnError)
+	count := fs.Int("count", 1, "number of messages to print")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: nexus nats peek [-count n] <stream> <seq>")
+	}
+
+	seq, err := strconv.ParseUint(fs.Arg(1), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid sequence %q: %w", fs.Arg(1), err)
+	}
+
+	stream, err := c.js.Stream(ctx, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to get stream %s: %w", fs.Arg(0), err)
+	}
+	lastSeq := stream.CachedInfo().State.LastSeq
+
+	for printed := 0; printed < *count && seq <= lastSeq; seq++ {
+		msg, err := stream.GetMsg(ctx, seq)
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			// 已刪除的消息會在序列號中留下空洞
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get message %d: %w", seq, err)
+		}
+
+		fmt.Fprintf(c.out, "[%d] %s %s\n", msg.Sequence, msg.Time.Format(time.RFC3339Nano), msg.Subject)
+		keys := make([]string, 0, len(msg.Header))
+		for k := range msg.Header {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(c.out, "  %s: %s\n", k, strings.Join(msg.Header[k], ", "))
+		}
+		fmt.Fprintf(c.out, "%s\n\n", msg.Data)
+		printed++
+	}
+	return nil
+}
+
+func (c *natsCLI) replay(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	target := fs.String("to", "", "subject to republish the messages to")
+	yes := fs.Bool("yes", false, "publish the messages instead of only listing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *target == "" || fs.NArg() < 2 || fs.NArg() > 3 {
+		return errors.New("usage: nexus nats replay -to <subject> [-yes] <stream> <from> [to]")
+	}
+
+	from, err := strconv.ParseUint(fs.Arg(1), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid sequence %q: %w", fs.Arg(1), err)
+	}
+
+	stream, err := c.js.Stream(ctx, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to get stream %s: %w", fs.Arg(0), err)
+	}
+
+	to := stream.CachedInfo().State.LastSeq
+	if fs.NArg() == 3 {
+		if to, err = strconv.ParseUint(fs.Arg(2), 10, 64); err != nil {
+			return fmt.Errorf("invalid sequence %q: %w", fs.Arg(2), err)
+		}
+	}
+	if to < from {
+		return fmt.Errorf("invalid range %d-%d", from, to)
+	}
+
+	replayed := 0
+	for seq := from; seq <= to; seq++ {
+		msg, err := stream.GetMsg(ctx, seq)
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get message %d: %w", seq, err)
+		}
+
+		if !*yes {
+			fmt.Fprintf(c.out, "would replay [%d] %s -> %s\n", seq, msg.Subject, *target)
+			continue
+		}
+
+		headers := nats.Header{}
+		for k, v := range msg.Header {
+			headers[k] = append([]string(nil), v...)
+		}
+		// 原始消息 ID 會被去重窗口丟棄，使用新的 ID
+		headers.Set(nats.MsgIdHdr, fmt.Sprintf("replay-%s-%d-%d", fs.Arg(0), seq, time.Now().UnixNano()))
+		headers.Set(replayStreamHeader, fs.Arg(0))
+		headers.Set(replaySequenceHeader, strconv.FormatUint(seq, 10))
+
+		if _, err = c.js.PublishMsg(ctx, &nats.Msg{Subject: *target, Data: msg.Data, Header: headers}); err != nil {
+			return fmt.Errorf("failed to replay message %d after %d replayed: %w", seq, replayed, err)
+		}
+		replayed++
+	}
+
+	if *yes {
+		fmt.Fprintf(c.out, "replayed %d messages from %s to %s\n", replayed, fs.Arg(0), *target)
+	}
+	return nil
+}
+
+func (c *natsCLI) printJSON(v any) error {
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
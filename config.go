@@ -66,20 +66,31 @@ type Config struct {
 	Stripe StripeConfig `yaml:"stripe"`
 }
 
-// LoadConfig loads the configuration from the given path
-func (c *Core) LoadConfig(path string) error {
+// ReadConfig reads and parses the configuration file at the given path
+func ReadConfig(path string) (*Config, error) {
 
 	// Read the configuration file
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
+		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
 	// Unmarshal the configuration file into the Config struct
-	c.config = &Config{}
-	if err := yaml.Unmarshal(data, c.config); err != nil {
-		return fmt.Errorf("failed to parse config file: %w", err)
+	config := &Config{}
+	if err = yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return config, nil
+}
+
+// LoadConfig loads the configuration from the given path
+func (c *Core) LoadConfig(path string) error {
+
+	config, err := ReadConfig(path)
+	if err != nil {
+		return err
 	}
+	c.config = config
 
 	// Log the successful loading of the configuration file
 	c.logger.Info("Configuration file loaded successfully")
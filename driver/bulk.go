@@ -0,0 +1,207 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// BulkQuerier 是批量寫入所需的最小接口，*pgxpool.Pool、*pgx.Conn 和 pgx.Tx 都滿足該接口
+type BulkQuerier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (commandTag pgconn.CommandTag, err error)
+}
+
+// bulkBatchSize 是每次 COPY 到臨時表並合併的最大行數
+const bulkBatchSize = 5000
+
+var stagingSeq atomic.Uint64
+
+// stagingSeqColumn 記錄行在臨時表中的寫入順序，用於合併同一批次中衝突鍵相同的行
+const stagingSeqColumn = "nexus_staging_seq"
+
+// BulkInsert 使用 COPY 將 rows 直接寫入 table，返回寫入的行數
+func BulkInsert(ctx context.Context, q BulkQuerier, table string, columns []string, rows [][]any) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	n, err := q.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, pgx.CopyFromRows(rows))
+	if err != nil {
+		return n, fmt.Errorf("failed to copy rows into %s: %w", table, err)
+	}
+	return n, nil
+}
+
+// BulkUpsert 先將 rows 通過 COPY 寫入臨時表，再使用 INSERT ... ON CONFLICT 合併到 table
+// conflictColumns 以外的列在衝突時會被更新；若所有列都是衝突列，則衝突時忽略
+// 同一次調用中衝突鍵相同的多行只保留最後一行
+// 所有語句在 q.Begin 開啟的事務中執行：q 是連接池時佔用一個連接，q 是事務時使用保存點
+// 返回受影響的行數
+func BulkUpsert(ctx context.Context, q BulkQuerier, table string, columns, conflictColumns []string, rows [][]any) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("bulk upsert into %s: no columns", table)
+	}
+	if len(conflictColumns) == 0 {
+		return 0, fmt.Errorf("bulk upsert into %s: no conflict columns", table)
+	}
+
+	tx, err := q.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin bulk upsert into %s: %w", table, err)
+	}
+	defer func() { _ = tx.Rollback(context.WithoutCancel(ctx)) }()
+
+	target := pgx.Identifier(strings.Split(table, ".")).Sanitize()
+	staging := fmt.Sprintf("nexus_staging_%d", stagingSeq.Add(1))
+	stagingIdent := pgx.Identifier{staging}.Sanitize()
+
+	if _, err = tx.Exec(ctx, fmt.Sprintf(
+		"CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS, %s bigserial) ON COMMIT DROP",
+		stagingIdent, target, stagingSeqColumn)); err != nil {
+		return 0, fmt.Errorf("failed to create staging table for %s: %w", table, err)
+	}
+
+	merge := buildUpsertSQL(target, stagingIdent, columns, conflictColumns)
+	truncate := fmt.Sprintf("TRUNCATE %s", stagingIdent)
+
+	var total int64
+	for start := 0; start < len(rows); start += bulkBatchSize {
+		end := min(start+bulkBatchSize, len(rows))
+
+		if _, err = tx.CopyFrom(ctx, pgx.Identifier{staging}, columns, pgx.CopyFromRows(rows[start:end])); err != nil {
+			return 0, fmt.Errorf("failed to copy rows into staging table for %s: %w", table, err)
+		}
+
+		tag, err := tx.Exec(ctx, merge)
+		if err != nil {
+			return 0, fmt.Errorf("failed to merge staging rows into %s: %w", table, err)
+		}
+		total += tag.RowsAffected()
+
+		if _, err = tx.Exec(ctx, truncate); err != nil {
+			return 0, fmt.Errorf("failed to truncate staging table for %s: %w", table, err)
+		}
+	}
+
+	// q 是事務時 ON COMMIT DROP 要等到外層事務提交，先行刪除
+	if _, err = tx.Exec(ctx, fmt.Sprintf("DROP TABLE %s", stagingIdent)); err != nil {
+		return 0, fmt.Errorf("failed to drop staging table for %s: %w", table, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit bulk upsert into %s: %w", table, err)
+	}
+	return total, nil
+}
+
+// BulkUpsertStructs 與 BulkUpsert 相同，但列名從結構體字段的 `db` 標籤推導
+// 沒有標籤的字段使用小寫的字段名，標籤為 "-" 的字段會被忽略
+func BulkUpsertStructs[T any](ctx context.Context, q BulkQuerier, table string, conflictColumns []string, items []T) (int64, error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	columns, indexes, err := structColumns(reflect.TypeFor[T]())
+	if err != nil {
+		return 0, err
+	}
+
+	rows := make([][]any, len(items))
+	for i := range items {
+		v := reflect.Indirect(reflect.ValueOf(&items[i]).Elem())
+		if !v.IsValid() {
+			return 0, fmt.Errorf("bulk upsert into %s: item %d is nil", table, i)
+		}
+		row := make([]any, len(indexes))
+		for j, index := range indexes {
+			field, err := v.FieldByIndexErr(index)
+			if err != nil {
+				row[j] = nil
+				continue
+			}
+			row[j] = field.Interface()
+		}
+		rows[i] = row
+	}
+
+	return BulkUpsert(ctx, q, table, columns, conflictColumns, rows)
+}
+
+// structColumns 返回結構體映射的列名及對應字段的索引
+func structColumns(t reflect.Type) ([]string, [][]int, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("expected struct type, got %s", t)
+	}
+
+	var (
+		columns []string
+		indexes [][]int
+	)
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() {
+			continue
+		}
+		tag, hasTag := field.Tag.Lookup("db")
+		if tag == "-" {
+			continue
+		}
+		// 未標記的嵌入結構體展開其字段
+		if field.Anonymous && !hasTag && field.Type.Kind() == reflect.Struct {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		columns = append(columns, name)
+		indexes = append(indexes, field.Index)
+	}
+
+	if len(columns) == 0 {
+		return nil, nil, fmt.Errorf("struct %s has no mapped columns", t)
+	}
+	return columns, indexes, nil
+}
+
+func buildUpsertSQL(target, staging string, columns, conflictColumns []string) string {
+	conflict := make(map[string]struct{}, len(conflictColumns))
+	for _, c := range conflictColumns {
+		conflict[c] = struct{}{}
+	}
+
+	quoted := make([]string, len(columns))
+	var updates []string
+	for i, c := range columns {
+		quoted[i] = pgx.Identifier{c}.Sanitize()
+		if _, ok := conflict[c]; !ok {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", quoted[i], quoted[i]))
+		}
+	}
+
+	quotedConflict := make([]string, len(conflictColumns))
+	for i, c := range conflictColumns {
+		quotedConflict[i] = pgx.Identifier{c}.Sanitize()
+	}
+
+	// DISTINCT ON 去除同一批次中衝突鍵重複的行，保留最後寫入的一行，避免 ON CONFLICT 重複更新同一行
+	cols := strings.Join(quoted, ", ")
+	keys := strings.Join(quotedConflict, ", ")
+	sql := fmt.Sprintf("INSERT INTO %s (%s) SELECT DISTINCT ON (%s) %s FROM %s ORDER BY %s, %s DESC ON CONFLICT (%s) ",
+		target, cols, keys, cols, staging, keys, pgx.Identifier{stagingSeqColumn}.Sanitize(), keys)
+	if len(updates) == 0 {
+		return sql + "DO NOTHING"
+	}
+	return sql + "DO UPDATE SET " + strings.Join(updates, ", ")
+}
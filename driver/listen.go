@@ -0,0 +1,155 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go.uber.org/zap"
+
+	"goflare.io/nexus/worker"
+)
+
+// NotificationHandler 處理 LISTEN 收到的通知
+type NotificationHandler func(ctx context.Context, notification *pgconn.Notification) error
+
+// Listen 在一個專用連接上監聽 channel，並將收到的通知交給 handler 處理
+// 連接斷開時會按退避策略重新獲取連接並重新 LISTEN
+// 該方法會阻塞直到 ctx 被取消，通常在單獨的 goroutine 中調用
+func (db *DB) Listen(ctx context.Context, channel string, handler NotificationHandler) error {
+	if channel == "" {
+		return fmt.Errorf("listen: empty channel")
+	}
+
+	logger := db.logger().With(zap.String("channel", channel))
+
+	workers, err := db.workers()
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; {
+		established, err := db.listen(ctx, channel, handler, workers, logger)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// 成功監聽過的連接斷開後，從第一次退避重新開始
+		if established {
+			attempt = 0
+		}
+
+		backoff := db.retry.Backoff(attempt)
+		logger.Warn("listener connection lost, reconnecting",
+			zap.Error(err),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("backoff", backoff))
+		attempt++
+
+		if err = sleepContext(ctx, backoff); err != nil {
+			return err
+		}
+	}
+}
+
+// listen 獲取一個連接並處理通知，直到連接出錯或 ctx 被取消
+// established 表示 LISTEN 是否已成功執行
+func (db *DB) listen(ctx context.Context, channel string, handler NotificationHandler, workers *worker.Pool, logger *zap.Logger) (established bool, err error) {
+	conn, err := db.Pool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire listener connection: %w", err)
+	}
+	defer releaseDedicated(conn)
+
+	if _, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return false, fmt.Errorf("failed to listen on %s: %w", channel, err)
+	}
+
+	logger.Info("listening for notifications")
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return true, fmt.Errorf("failed to wait for notification: %w", err)
+		}
+		dispatch(ctx, notification, handler, workers, logger)
+	}
+}
+
+// workers 返回分發通知的 worker pool，Workers 為 nil 時按 WorkerConfig 創建
+// 不使用 LISTEN 的應用不會創建 worker pool
+func (db *DB) workers() (*worker.Pool, error) {
+	db.workersMu.Lock()
+	defer db.workersMu.Unlock()
+
+	if db.Workers == nil && db.WorkerConfig != nil {
+		pool, err := worker.NewPool(*db.WorkerConfig, db.logger())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create listener worker pool: %w", err)
+		}
+		db.Workers = pool
+	}
+	return db.Workers, nil
+}
+
+// ReleaseWorkers 釋放分發通知的 worker pool
+func (db *DB) ReleaseWorkers() {
+	db.workersMu.Lock()
+	defer db.workersMu.Unlock()
+
+	if db.Workers != nil {
+		db.Workers.Release()
+		db.Workers = nil
+	}
+}
+
+// dispatch 將通知交給 worker pool 處理，沒有 worker pool 時直接處理
+func dispatch(ctx context.Context, notification *pgconn.Notification, handler NotificationHandler, workers *worker.Pool, logger *zap.Logger) {
+	task := func() error {
+		if err := handler(ctx, notification); err != nil {
+			logger.Error("failed to handle notification",
+				zap.Error(err),
+				zap.Uint32("pid", notification.PID))
+			return err
+		}
+		return nil
+	}
+
+	if workers == nil {
+		_ = task()
+		return
+	}
+
+	if err := workers.Submit(ctx, task); err != nil {
+		logger.Error("failed to submit notification to worker pool",
+			zap.Error(err),
+			zap.Uint32("pid", notification.PID))
+	}
+}
+
+// Notify 向 channel 發送通知
+func (db *DB) Notify(ctx context.Context, channel, payload string) error {
+	if _, err := db.Pool.Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload); err != nil {
+		return fmt.Errorf("failed to notify %s: %w", channel, err)
+	}
+	return nil
+}
+
+// releaseDedicated 關閉專用連接後歸還給連接池，避免 LISTEN 或會話鎖狀態洩漏給其他使用者
+func releaseDedicated(conn *pgxpool.Conn) {
+	_ = conn.Conn().Close(context.Background())
+	conn.Release()
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
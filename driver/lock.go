@@ -0,0 +1,122 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go.uber.org/zap"
+)
+
+// ErrLockNotAcquired 表示 TryWithAdvisoryLock 未能獲取到鎖
+var ErrLockNotAcquired = errors.New("advisory lock not acquired")
+
+// ErrLockLost 表示持有鎖的連接已斷開，鎖已被服務器釋放
+var ErrLockLost = errors.New("advisory lock lost")
+
+// lockCheckInterval 是持鎖期間檢查連接存活的間隔
+const lockCheckInterval = 5 * time.Second
+
+// AdvisoryLockKey 將字符串 key 哈希為 pg_advisory_lock 使用的 64 位鍵
+func AdvisoryLockKey(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int64(h.Sum64())
+}
+
+// WithAdvisoryLock 獲取 key 對應的會話級 advisory lock 並執行 fn，阻塞直到獲取成功或 ctx 被取消
+// 鎖在專用連接上持有；連接斷開時傳給 fn 的 ctx 會被取消，並返回 ErrLockLost
+func (db *DB) WithAdvisoryLock(ctx context.Context, key string, fn func(ctx context.Context) error) error {
+	conn, err := db.Pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire lock connection: %w", err)
+	}
+
+	lockKey := AdvisoryLockKey(key)
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		releaseDedicated(conn)
+		return fmt.Errorf("failed to acquire advisory lock %q: %w", key, err)
+	}
+
+	return db.runLocked(ctx, conn, key, lockKey, fn)
+}
+
+// TryWithAdvisoryLock 嘗試獲取 key 對應的 advisory lock，獲取成功時執行 fn
+// 鎖已被其他會話持有時立即返回 ErrLockNotAcquired
+func (db *DB) TryWithAdvisoryLock(ctx context.Context, key string, fn func(ctx context.Context) error) error {
+	conn, err := db.Pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire lock connection: %w", err)
+	}
+
+	lockKey := AdvisoryLockKey(key)
+
+	var acquired bool
+	if err = conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", lockKey).Scan(&acquired); err != nil {
+		releaseDedicated(conn)
+		return fmt.Errorf("failed to try advisory lock %q: %w", key, err)
+	}
+	if !acquired {
+		conn.Release()
+		return ErrLockNotAcquired
+	}
+
+	return db.runLocked(ctx, conn, key, lockKey, fn)
+}
+
+// runLocked 在持鎖期間執行 fn，並監控持鎖連接；結束後釋放鎖和連接
+func (db *DB) runLocked(ctx context.Context, conn *pgxpool.Conn, key string, lockKey int64, fn func(ctx context.Context) error) error {
+	logger := db.logger().With(zap.String("lock", key))
+
+	fnCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	done := make(chan struct{})
+	monitorDone := make(chan struct{})
+	go func() {
+		defer close(monitorDone)
+		ticker := time.NewTicker(lockCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-fnCtx.Done():
+				return
+			case <-ticker.C:
+				if err := conn.Ping(fnCtx); err != nil && fnCtx.Err() == nil {
+					logger.Error("lost advisory lock connection", zap.Error(err))
+					cancel(ErrLockLost)
+					return
+				}
+			}
+		}
+	}()
+
+	fnErr := fn(fnCtx)
+	close(done)
+	<-monitorDone
+
+	if errors.Is(context.Cause(fnCtx), ErrLockLost) {
+		releaseDedicated(conn)
+		return errors.Join(ErrLockLost, fnErr)
+	}
+
+	// 使用獨立的 ctx 釋放鎖，保證 ctx 取消後鎖也能被釋放
+	unlockCtx, unlockCancel := context.WithTimeout(context.WithoutCancel(ctx), lockCheckInterval)
+	defer unlockCancel()
+
+	if _, err := conn.Exec(unlockCtx, "SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+		// 關閉連接時服務器會釋放該會話持有的所有鎖
+		logger.Warn("failed to release advisory lock, closing connection", zap.Error(err))
+		releaseDedicated(conn)
+		return fnErr
+	}
+
+	conn.Release()
+	return fnErr
+}
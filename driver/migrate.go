@@ -0,0 +1,19 @@
+package driver
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// WithMigrationsTable 在 golang-migrate 的數據庫 URL 中設置 x-migrations-table
+// 內置包的遷移各自記錄版本，不能與應用的 schema_migrations 共用一張表
+func WithMigrationsTable(databaseURL, table string) (string, error) {
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse database url: %w", err)
+	}
+	q := u.Query()
+	q.Set("x-migrations-table", table)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 
 	"go.uber.org/zap"
 
@@ -17,6 +18,9 @@ import (
 
 type NatsHandler func(ctx context.Context, event *nats.Msg) error
 
+// JetStreamHandler 處理通過 jetstream 包消費的消息
+type JetStreamHandler func(ctx context.Context, msg jetstream.Msg) error
+
 // NatsConfig 定義 NATS 配置
 type NatsConfig struct {
 	URL        string        `yaml:"url"`
@@ -24,37 +28,130 @@ type NatsConfig struct {
 	MaxAge     time.Duration `yaml:"max_age"`
 	MaxMsgs    int64         `yaml:"max_msgs"`
 	MaxBytes   int64         `yaml:"max_bytes"`
-	Worker     worker.Config `yaml:"worker"` // 添加 worker 配置
+
+	// 連接選項
+	Name      string              `yaml:"name"` // 客戶端名稱，默認為 StreamName
+	Auth      NatsAuthConfig      `yaml:"auth"`
+	TLS       NatsTLSConfig       `yaml:"tls"`
+	Reconnect NatsReconnectConfig `yaml:"reconnect"`
+
+	// Embedded 在 local 模式下啟動進程內的 NATS 服務器並忽略 URL，需要使用 nats_embedded 標籤構建
+	Embedded       bool               `yaml:"embedded"`
+	EmbeddedServer EmbeddedNatsConfig `yaml:"embedded_server"`
+
+	// 主 stream 的其他配置，含義見 StreamConfig
+	Subjects        []string      `yaml:"subjects"`
+	Storage         string        `yaml:"storage"`
+	Retention       string        `yaml:"retention"`
+	Replicas        int           `yaml:"replicas"`
+	Discard         string        `yaml:"discard"`
+	DuplicateWindow time.Duration `yaml:"duplicate_window"`
+	MaxMsgSize      int32         `yaml:"max_msg_size"`
+
+	// Streams 聲明額外的 stream；未設置 StreamName 時第一個作為主 stream
+	Streams []StreamConfig `yaml:"streams"`
+
+	// 啟動時創建或更新的 KV bucket 和 Object Store
+	KeyValues    []KeyValueConfig    `yaml:"key_values"`
+	ObjectStores []ObjectStoreConfig `yaml:"object_stores"`
+
+	Codec           string           `yaml:"codec"`             // 請求的編碼格式 (Content-Type)，默認 application/json
+	EventSource     string           `yaml:"event_source"`      // 事件的默認來源，默認為 StreamName
+	AsyncMaxPending int              `yaml:"async_max_pending"` // 未確認的異步發布數量上限
+	NakDelay        time.Duration    `yaml:"nak_delay"`         // 處理失敗後首次重新投遞的延遲
+	HandlerTimeout  time.Duration    `yaml:"handler_timeout"`   // 處理單條消息的超時時間，默認為消費者的 AckWait
+	DurablePrefix   string           `yaml:"durable_prefix"`    // 持久消費者名稱的前綴，默認為 StreamName
+	PullBatchSize   int              `yaml:"pull_batch_size"`   // 拉取消費者每次拉取的最大消息數
+	PullMaxWait     time.Duration    `yaml:"pull_max_wait"`     // 拉取消費者每次等待消息的最長時間
+	DeadLetter      DeadLetterConfig `yaml:"dead_letter"`       // 超過最大投遞次數的消息處理
+	Schedule        ScheduleConfig   `yaml:"schedule"`          // 延遲消息
+	Usage           UsageConfig      `yaml:"usage"`             // stream 用量告警
+	Worker          worker.Config    `yaml:"worker"`            // 添加 worker 配置
+	Retry           RetryConfig      `yaml:"retry"`             // 啟動時連接的重試策略
+	PublishRetry    RetryPolicy      `yaml:"publish_retry"`     // 發布失敗時的重試策略
 }
 
 // DefaultConfig 返回默認配置
 func DefaultConfig(name string) NatsConfig {
 	return NatsConfig{
-		StreamName: name,
-		MaxAge:     24 * time.Hour,
-		MaxMsgs:    10000,
-		MaxBytes:   1024 * 1024 * 1024,
-		Worker:     worker.DefaultConfig(),
+		StreamName:      name,
+		MaxAge:          24 * time.Hour,
+		MaxMsgs:         10000,
+		MaxBytes:        1024 * 1024 * 1024,
+		Storage:         "file",
+		Retention:       "workqueue",
+		Replicas:        1,
+		NakDelay:        time.Second,
+		DurablePrefix:   name,
+		PullBatchSize:   10,
+		PullMaxWait:     5 * time.Second,
+		AsyncMaxPending: defaultAsyncMaxPending,
+		Worker:          worker.DefaultConfig(),
+		Retry:           DefaultRetryConfig(),
+		PublishRetry:    DefaultRetryPolicy(),
 	}
 }
 
 // NatsManager 定義 JetStream 管理器的接口
 type NatsManager interface {
-	Publish(ctx context.Context, subject string, data []byte) error
+	Publish(ctx context.Context, subject string, data []byte, opts ...PublishOption) error
+	PublishWithHeaders(ctx context.Context, subject string, data []byte, headers nats.Header, opts ...PublishOption) error
+	PublishMsg(ctx context.Context, event Event, opts ...PublishOption) error
+	PublishAt(ctx context.Context, subject string, data []byte, when time.Time, opts ...PublishOption) error
+	PublishAfter(ctx context.Context, subject string, data []byte, delay time.Duration, opts ...PublishOption) error
+	PublishAsync(ctx context.Context, subject string, data []byte) (PublishFuture, error)
+	PublishMsgAsync(ctx context.Context, event Event) (PublishFuture, error)
+	Flush(ctx context.Context) error
 	Subscribe(subject string, handler NatsHandler, opts ...nats.SubOpt) (*nats.Subscription, error)
+	SubscribeDurable(subject string, handler NatsHandler, opts ...nats.SubOpt) (*nats.Subscription, error)
+	QueueSubscribe(subject, queue string, handler NatsHandler, opts ...nats.SubOpt) (*nats.Subscription, error)
+	PullSubscribe(subject string, handler NatsHandler, opts ...nats.SubOpt) (*nats.Subscription, error)
+	ReplayDLQ(ctx context.Context, subject string, filter DLQFilter) (int, error)
+	Consume(ctx context.Context, stream string, config jetstream.ConsumerConfig, handler JetStreamHandler) (jetstream.ConsumeContext, error)
+	OrderedConsume(ctx context.Context, stream string, config jetstream.OrderedConsumerConfig, handler JetStreamHandler) (jetstream.ConsumeContext, error)
+	JetStream() jetstream.JetStream
+	KeyValue(ctx context.Context, bucket string, config *KeyValueConfig) (jetstream.KeyValue, error)
+	ObjectStore(ctx context.Context, bucket string, config *ObjectStoreConfig) (jetstream.ObjectStore, error)
+	Request(ctx context.Context, subject string, req, resp any) error
+	HandleRequest(subject string, handler RequestHandler) (*nats.Subscription, error)
+	Use(middlewares ...NatsMiddleware)
+	OnUsageAlert(hooks ...UsageHook)
+	Usage(ctx context.Context) ([]StreamUsage, error)
 	HealthCheck() error
+	Metrics(ctx context.Context) (*NatsMetrics, error)
 	GetMetrics() map[string]any
 	Close() error
 }
 
+// managementTimeout 是 stream 管理調用的默認超時時間
+const managementTimeout = 10 * time.Second
+
 // jetStreamNatsManager 實現 NatsManager 接口
+// stream 管理、發布和新版消費者使用 jetstream 包；基於 nats.SubOpt 的訂閱方法仍使用舊版 JetStreamContext
 type jetStreamNatsManager struct {
-	nc     *nats.Conn
-	js     nats.JetStreamContext
-	logger *zap.Logger
-	config NatsConfig
-	pool   *worker.Pool
-	mu     sync.RWMutex
+	nc        *nats.Conn
+	js        nats.JetStreamContext
+	jetStream jetstream.JetStream
+	logger    *zap.Logger
+	config    NatsConfig
+	pool      *worker.Pool
+	mu        sync.RWMutex
+
+	// asyncErrs 收集異步發布的失敗
+	asyncErrs *asyncErrors
+
+	// metrics 按主題記錄發布和處理指標
+	metrics *natsMetrics
+
+	// usage 記錄 stream 用量的告警級別和回調
+	usage *usageTracker
+
+	// middlewares 應用於訂閱的 handler
+	middlewares []NatsMiddleware
+
+	// ctx 在 Close 時被取消，用於停止後台任務
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // NewNatsManager 創建新的 JetStream 管理器
@@ -64,70 +161,120 @@ func NewNatsManager(
 	pool *worker.Pool,
 	logger *zap.Logger) (NatsManager, error) {
 
+	if err := config.Usage.withDefaults().validate(); err != nil {
+		pool.Release()
+		return nil, err
+	}
+
 	js, err := nc.JetStream()
 	if err != nil {
 		pool.Release()
 		return nil, fmt.Errorf("failed to get jetstream context: %w", err)
 	}
 
+	if config.StreamName == "" && len(config.Streams) > 0 {
+		config.StreamName = config.Streams[0].Name
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
 	mgr := &jetStreamNatsManager{
-		nc:     nc,
-		js:     js,
-		logger: logger,
-		config: config,
-		pool:   pool,
+		nc:        nc,
+		js:        js,
+		logger:    logger,
+		config:    config,
+		pool:      pool,
+		ctx:       ctx,
+		cancel:    cancel,
+		asyncErrs: &asyncErrors{},
+		metrics:   newNatsMetrics(),
+		usage:     newUsageTracker(),
 	}
 
-	if err = mgr.setupStream(); err != nil {
+	maxPending := config.AsyncMaxPending
+	if maxPending <= 0 {
+		maxPending = defaultAsyncMaxPending
+	}
+
+	mgr.jetStream, err = jetstream.New(nc,
+		jetstream.WithPublishAsyncMaxPending(maxPending),
+		jetstream.WithPublishAsyncErrHandler(mgr.asyncErrHandler))
+	if err != nil {
+		cancel()
 		pool.Release()
-		if errors.Is(err, nats.ErrJetStreamNotEnabled) {
+		return nil, fmt.Errorf("failed to create jetstream: %w", err)
+	}
+
+	setupCtx, setupCancel := context.WithTimeout(ctx, managementTimeout)
+	defer setupCancel()
+
+	if err = mgr.setupStreams(setupCtx); err != nil {
+		if errors.Is(err, jetstream.ErrJetStreamNotEnabled) {
+			cancel()
+			pool.Release()
 			return nil, fmt.Errorf("jetstream not enabled: %w", err)
 		}
 		mgr.logger.Warn("stream setup issue, but continuing", zap.Error(err))
 	}
 
-	return mgr, nil
-}
+	if config.DeadLetter.Enabled {
+		if err = mgr.setupDeadLetterStream(setupCtx); err != nil {
+			// 主題重疊時死信會回到原 stream 循環投遞，不能繼續
+			if errors.Is(err, ErrDeadLetterOverlap) {
+				cancel()
+				pool.Release()
+				return nil, err
+			}
+			mgr.logger.Warn("dead letter stream setup issue, but continuing", zap.Error(err))
+		}
+	}
 
-func (m *jetStreamNatsManager) setupStream() error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	if config.Schedule.Enabled {
+		if err = mgr.setupScheduler(setupCtx); err != nil {
+			// 主題重疊時延遲消息會被立即投遞，不能繼續
+			if errors.Is(err, ErrScheduleOverlap) {
+				cancel()
+				pool.Release()
+				return nil, err
+			}
+			mgr.logger.Warn("scheduler setup issue, but continuing", zap.Error(err))
+		}
+	}
 
-	config := &nats.StreamConfig{
-		Name:      m.config.StreamName,
-		Storage:   nats.MemoryStorage,
-		Retention: nats.WorkQueuePolicy,
-		MaxAge:    m.config.MaxAge,
-		MaxMsgs:   m.config.MaxMsgs,
-		MaxBytes:  m.config.MaxBytes,
+	if err = mgr.setupBuckets(setupCtx); err != nil {
+		mgr.logger.Warn("bucket setup issue, but continuing", zap.Error(err))
 	}
 
-	return m.createOrUpdateStream(config)
+	if config.Usage.Interval > 0 {
+		go mgr.watchUsage(config.Usage.Interval)
+	}
+
+	return mgr, nil
 }
 
-func (m *jetStreamNatsManager) createOrUpdateStream(config *nats.StreamConfig) error {
+func (m *jetStreamNatsManager) createOrUpdateStream(ctx context.Context, config jetstream.StreamConfig) error {
 
 	m.logger.Info("creating or updating stream",
 		zap.String("name", config.Name))
-	stream, err := m.js.StreamInfo(config.Name)
+	stream, err := m.jetStream.Stream(ctx, config.Name)
 	if err != nil {
 		m.logger.Info("failed to get stream info",
 			zap.Error(err),
 			zap.String("name", config.Name))
-		if errors.Is(err, nats.ErrStreamNotFound) {
-			return m.createStream(config)
+		if errors.Is(err, jetstream.ErrStreamNotFound) {
+			return m.createStream(ctx, config)
 		}
 		return fmt.Errorf("failed to get stream info: %w", err)
 	}
 
-	return m.updateStreamIfNeeded(stream, config)
+	return m.updateStreamIfNeeded(ctx, stream.CachedInfo(), config)
 }
 
-func (m *jetStreamNatsManager) createStream(config *nats.StreamConfig) error {
+func (m *jetStreamNatsManager) createStream(ctx context.Context, config jetstream.StreamConfig) error {
 
 	m.logger.Info("creating stream",
 		zap.String("name", config.Name))
-	if _, err := m.js.AddStream(config); err != nil {
+	if _, err := m.jetStream.CreateStream(ctx, config); err != nil {
 		m.logger.Warn("failed to create stream",
 			zap.Error(err),
 			zap.String("name", config.Name))
@@ -144,15 +291,15 @@ func (m *jetStreamNatsManager) createStream(config *nats.StreamConfig) error {
 	return nil
 }
 
-func (m *jetStreamNatsManager) updateStreamIfNeeded(stream *nats.StreamInfo, config *nats.StreamConfig) error {
+func (m *jetStreamNatsManager) updateStreamIfNeeded(ctx context.Context, stream *jetstream.StreamInfo, config jetstream.StreamConfig) error {
 
-	if !m.isStreamConfigDifferent(stream.Config, *config) {
+	if !m.isStreamConfigDifferent(stream.Config, config) {
 		m.logger.Info("stream config is up to date",
 			zap.String("name", config.Name))
 		return nil
 	}
 
-	if _, err := m.js.UpdateStream(config); err != nil {
+	if _, err := m.jetStream.UpdateStream(ctx, config); err != nil {
 		m.logger.Warn("failed to update stream config",
 			zap.Error(err),
 			zap.String("name", config.Name))
@@ -164,89 +311,86 @@ func (m *jetStreamNatsManager) updateStreamIfNeeded(stream *nats.StreamInfo, con
 	return nil
 }
 
-func (m *jetStreamNatsManager) isStreamConfigDifferent(a, b nats.StreamConfig) bool {
+func (m *jetStreamNatsManager) isStreamConfigDifferent(a, b jetstream.StreamConfig) bool {
 	return a.MaxAge != b.MaxAge ||
 		a.MaxMsgs != b.MaxMsgs ||
 		a.MaxBytes != b.MaxBytes ||
 		a.Storage != b.Storage ||
 		a.Retention != b.Retention ||
-		!stringSlicesEqual(a.Subjects, b.Subjects)
+		a.Discard != b.Discard ||
+		a.Replicas != b.Replicas ||
+		a.MaxMsgSize != b.MaxMsgSize ||
+		// 未配置去重窗口或主題時使用服務器默認值，不視為差異
+		(b.Duplicates != 0 && a.Duplicates != b.Duplicates) ||
+		(len(b.Subjects) > 0 && !stringSlicesEqual(a.Subjects, b.Subjects))
 }
 
 // Subscribe 使用 worker pool 處理訂閱
+// handler 返回 nil 時確認消息，返回包裝了 ErrPermanent 的錯誤時終止消息，其他錯誤延遲後重新投遞
 func (m *jetStreamNatsManager) Subscribe(subject string, handler NatsHandler, opts ...nats.SubOpt) (*nats.Subscription, error) {
-	// 使用 worker pool 包裝 handler
-	wrappedHandler := func(msg *nats.Msg) {
-		if err := m.pool.Submit(context.Background(), func() error {
-			if err := handler(context.Background(), msg); err != nil {
-				m.logger.Error("failed to handle message",
-					zap.Error(err),
-					zap.String("subject", subject))
-			}
-			return nil
-		}); err != nil {
-			m.logger.Error("failed to submit message to worker pool",
-				zap.Error(err),
-				zap.String("subject", subject))
-			// 如果提交失敗，不確認消息，允許重新投遞
-			return
-		}
-	}
+	state := newSubscriptionState(subject)
 
 	// 合併默認選項和自定義選項
 	allOpts := m.getSubscriptionNatsOption(opts...)
 
-	sub, err := m.js.Subscribe(subject, wrappedHandler, allOpts...)
+	sub, err := m.js.Subscribe(subject, m.msgHandler(state, handler), allOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to subscribe: %w", err)
 	}
 
+	m.trackSubscription(sub, state)
 	return sub, nil
 }
 
-func (m *jetStreamNatsManager) Publish(ctx context.Context, subject string, data []byte) error {
-	const (
-		maxRetries = 3
-		backoff    = time.Millisecond * 100
-	)
-
-	var lastErr error
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if err := ctx.Err(); err != nil {
-			return fmt.Errorf("context cancelled: %w", err)
+// msgHandler 使用 worker pool 包裝 handler
+func (m *jetStreamNatsManager) msgHandler(state *subscriptionState, handler NatsHandler) nats.MsgHandler {
+	handler = m.applyMiddlewares(handler)
+	return func(msg *nats.Msg) {
+		if err := m.pool.Submit(context.Background(), func() error {
+			return m.handleMessage(m.ctx, state, legacyMsg{msg}, func(ctx context.Context) error {
+				return handler(ctx, msg)
+			})
+		}); err != nil {
+			m.logger.Error("failed to submit message to worker pool",
+				zap.Error(err),
+				zap.String("subject", state.subject))
+			// 如果提交失敗，延遲後重新投遞
+			m.nakMessage(state.subject, legacyMsg{msg})
+			return
 		}
+	}
+}
 
-		if err := m.publishWithTimeout(ctx, subject, data); err != nil {
-			lastErr = err
-			if attempt == maxRetries-1 {
-				break
-			}
-			m.logRetryAttempt(subject, attempt, maxRetries, err)
-			if !m.shouldRetry(err) {
-				break
-			}
-			if err = m.sleep(ctx, backoff, attempt); err != nil {
-				return err
-			}
-			continue
-		}
-		return nil
+// trackSubscription 訂閱創建後從消費者信息中讀取實際的 AckWait 和 MaxDeliver
+func (m *jetStreamNatsManager) trackSubscription(sub *nats.Subscription, state *subscriptionState) {
+	if info, err := sub.ConsumerInfo(); err == nil {
+		state.update(info.Config.AckWait, info.Config.MaxDeliver)
 	}
+}
+
+// Publish 發布消息，失敗時按 PublishRetry 重試，opts 可覆蓋單次調用的重試策略
+func (m *jetStreamNatsManager) Publish(ctx context.Context, subject string, data []byte, opts ...PublishOption) error {
+	return m.publishMsg(ctx, &nats.Msg{Subject: subject, Data: data}, opts...)
+}
 
-	return fmt.Errorf("failed to publish after %d attempts: %w", maxRetries, lastErr)
+// PublishWithHeaders 發布帶有消息頭的消息，重試策略與 Publish 相同
+func (m *jetStreamNatsManager) PublishWithHeaders(ctx context.Context, subject string, data []byte, headers nats.Header, opts ...PublishOption) error {
+	return m.publishMsg(ctx, &nats.Msg{Subject: subject, Data: data, Header: headers}, opts...)
 }
 
-func (m *jetStreamNatsManager) publishWithTimeout(ctx context.Context, subject string, data []byte) error {
-	ack, err := m.js.Publish(subject, data, nats.Context(ctx))
+func (m *jetStreamNatsManager) publishWithTimeout(ctx context.Context, msg *nats.Msg) error {
+	start := time.Now()
+	ack, err := m.jetStream.PublishMsg(ctx, msg)
+	m.metrics.published(msg.Subject, time.Since(start), err)
 	if err != nil {
 		m.logger.Error("failed to publish message",
 			zap.Error(err),
-			zap.String("subject", subject))
+			zap.String("subject", msg.Subject))
 		return err
 	}
 
 	m.logger.Info("message published",
-		zap.String("subject", subject),
+		zap.String("subject", msg.Subject),
 		zap.Uint64("sequence", ack.Sequence))
 	return nil
 }
@@ -254,47 +398,91 @@ func (m *jetStreamNatsManager) publishWithTimeout(ctx context.Context, subject s
 func (m *jetStreamNatsManager) getSubscriptionNatsOption(opts ...nats.SubOpt) []nats.SubOpt {
 	defaultOpts := []nats.SubOpt{
 		nats.ManualAck(),
-		nats.AckWait(5 * time.Second),
-		nats.MaxDeliver(3),
+		nats.AckWait(defaultAckWait),
+		nats.MaxDeliver(defaultMaxDeliver),
 		nats.DeliverAll(),
 	}
 	return append(defaultOpts, opts...)
 }
 
 func (m *jetStreamNatsManager) getDurableName(subject string) string {
-	return fmt.Sprintf("CHECKOUT_%s", strings.ReplaceAll(subject, ".", "_"))
+	prefix := m.config.DurablePrefix
+	if prefix == "" {
+		prefix = m.config.StreamName
+	}
+	return fmt.Sprintf("%s_%s", prefix, durableNameReplacer.Replace(subject))
 }
 
+// HealthCheck 檢查連接和 stream 用量
+// 用量達到告警閾值或無法讀取次要 stream 時返回 *StreamDegradedError，可用 errors.Is(err, ErrStreamDegraded) 區分降級和不可用
+// 無法讀取主 stream 或所有 stream 時視為不可用
 func (m *jetStreamNatsManager) HealthCheck() error {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	streamInfo, err := m.js.StreamInfo(m.config.StreamName)
-	if err != nil {
-		return fmt.Errorf("failed to get stream info: %w", err)
+	if status := m.nc.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("nats connection is %s", status)
 	}
 
-	m.checkMessageLimit(streamInfo)
+	ctx, cancel := context.WithTimeout(m.ctx, managementTimeout)
+	defer cancel()
+
+	degraded, failed := m.checkUsage(ctx)
+	all := len(failed) == len(m.config.streamConfigs())
+	for _, f := range failed {
+		if all || f.Stream == m.config.StreamName {
+			return f
+		}
+	}
+	if len(degraded) > 0 || len(failed) > 0 {
+		return &StreamDegradedError{Usage: degraded, Unavailable: failed}
+	}
 	return nil
 }
 
 // GetMetrics 返回組合的指標
+// 保留用於兼容，新代碼應使用 Metrics
 func (m *jetStreamNatsManager) GetMetrics() map[string]any {
-	metrics := m.pool.GetMetrics()
+	ctx, cancel := context.WithTimeout(m.ctx, managementTimeout)
+	defer cancel()
+
+	snapshot, _ := m.Metrics(ctx)
 
-	// 獲取 stream 信息
-	streamInfo, err := m.js.StreamInfo(m.config.StreamName)
-	if err == nil && streamInfo != nil {
-		metrics["stream_messages"] = streamInfo.State.Msgs
-		metrics["stream_bytes"] = streamInfo.State.Bytes
-		metrics["stream_consumers"] = streamInfo.State.Consumers
+	metrics := snapshot.Workers
+	if metrics == nil {
+		metrics = make(map[string]any)
 	}
 
+	// 主 stream 的狀態
+	for _, stream := range snapshot.Streams {
+		if stream.Name == m.config.StreamName {
+			metrics["stream_messages"] = stream.Messages
+			metrics["stream_bytes"] = stream.Bytes
+			metrics["stream_consumers"] = stream.Consumers
+		}
+	}
+
+	retries := make(map[string]uint64)
+	failures := make(map[string]uint64)
+	for subject, publish := range snapshot.Publish {
+		if publish.Retries > 0 {
+			retries[subject] = publish.Retries
+		}
+		if publish.Failures > 0 {
+			failures[subject] = publish.Failures
+		}
+	}
+	metrics["publish_retries"] = retries
+	metrics["publish_failures"] = failures
+
 	return metrics
 }
 
 // Close 實現優雅關閉
 func (m *jetStreamNatsManager) Close() error {
+	// 停止後台任務
+	m.cancel()
+
 	// 先關閉 worker pool
 	if m.pool != nil {
 		m.pool.Release()
@@ -308,17 +496,6 @@ func (m *jetStreamNatsManager) Close() error {
 	return nil
 }
 
-func (m *jetStreamNatsManager) checkMessageLimit(streamInfo *nats.StreamInfo) {
-	usagePercentage := float64(streamInfo.State.Msgs) / float64(streamInfo.Config.MaxMsgs) * 100
-	if usagePercentage >= 90 {
-		m.logger.Warn("stream approaching message limit",
-			zap.String("stream", m.config.StreamName),
-			zap.Uint64("current_msgs", streamInfo.State.Msgs),
-			zap.Int64("max_msgs", streamInfo.Config.MaxMsgs),
-			zap.Float64("usage_percentage", usagePercentage))
-	}
-}
-
 func stringSlicesEqual(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
@@ -330,31 +507,3 @@ func stringSlicesEqual(a, b []string) bool {
 	}
 	return true
 }
-
-func (m *jetStreamNatsManager) logRetryAttempt(subject string, attempt, maxRetries int, err error) {
-	m.logger.Warn("failed to publish message, retrying",
-		zap.Error(err),
-		zap.String("subject", subject),
-		zap.Int("attempt", attempt+1),
-		zap.Int("maxRetries", maxRetries))
-}
-
-func (m *jetStreamNatsManager) shouldRetry(err error) bool {
-	if errors.Is(err, nats.ErrJetStreamNotEnabled) ||
-		errors.Is(err, nats.ErrInvalidJSAck) {
-		return false
-	}
-	return true
-}
-
-func (m *jetStreamNatsManager) sleep(ctx context.Context, backoff time.Duration, attempt int) error {
-	// 使用指數退避策略
-	sleepTime := backoff * time.Duration(1<<attempt)
-
-	select {
-	case <-ctx.Done():
-		return fmt.Errorf("context cancelled during retry: %w", ctx.Err())
-	case <-time.After(sleepTime):
-		return nil
-	}
-}
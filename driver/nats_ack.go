@@ -0,0 +1,280 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"go.uber.org/zap"
+)
+
+// ErrPermanent 標記不可重試的處理錯誤，消息會被終止而不再重新投遞
+var ErrPermanent = errors.New("permanent error")
+
+const (
+	// defaultAckWait 是訂閱的默認確認超時時間
+	defaultAckWait = 5 * time.Second
+
+	// defaultMaxDeliver 是訂閱的默認最大投遞次數
+	defaultMaxDeliver = 3
+
+	// maxNakDelay 是重新投遞延遲的上限
+	maxNakDelay = time.Minute
+)
+
+// Permanent 將 err 包裝為不可重試的錯誤
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+// jsMessage 統一舊版 *nats.Msg 與 jetstream.Msg 的確認操作
+type jsMessage interface {
+	Subject() string
+	Data() []byte
+	Headers() nats.Header
+	Ack() error
+	NakWithDelay(delay time.Duration) error
+	Term() error
+	InProgress() error
+	Metadata() (*jetstream.MsgMetadata, error)
+}
+
+// legacyMsg 將舊版 API 的 *nats.Msg 適配為 jsMessage
+type legacyMsg struct {
+	msg *nats.Msg
+}
+
+func (l legacyMsg) Subject() string      { return l.msg.Subject }
+func (l legacyMsg) Data() []byte         { return l.msg.Data }
+func (l legacyMsg) Headers() nats.Header { return l.msg.Header }
+func (l legacyMsg) Ack() error           { return l.msg.Ack() }
+func (l legacyMsg) Term() error          { return l.msg.Term() }
+func (l legacyMsg) InProgress() error    { return l.msg.InProgress() }
+
+func (l legacyMsg) NakWithDelay(delay time.Duration) error {
+	return l.msg.NakWithDelay(delay)
+}
+
+func (l legacyMsg) Metadata() (*jetstream.MsgMetadata, error) {
+	meta, err := l.msg.Metadata()
+	if err != nil {
+		return nil, err
+	}
+	return &jetstream.MsgMetadata{
+		Sequence: jetstream.SequencePair{
+			Consumer: meta.Sequence.Consumer,
+			Stream:   meta.Sequence.Stream,
+		},
+		NumDelivered: meta.NumDelivered,
+		NumPending:   meta.NumPending,
+		Timestamp:    meta.Timestamp,
+		Stream:       meta.Stream,
+		Consumer:     meta.Consumer,
+		Domain:       meta.Domain,
+	}, nil
+}
+
+// subscriptionState 保存訂閱的消費者配置，供消息處理時使用
+type subscriptionState struct {
+	subject    string
+	ackWait    atomic.Int64
+	maxDeliver atomic.Int64
+}
+
+func newSubscriptionState(subject string) *subscriptionState {
+	s := &subscriptionState{subject: subject}
+	s.ackWait.Store(int64(defaultAckWait))
+	s.maxDeliver.Store(defaultMaxDeliver)
+	return s
+}
+
+// update 使用服務器返回的消費者配置更新狀態
+func (s *subscriptionState) update(ackWait time.Duration, maxDeliver int) {
+	if ackWait > 0 {
+		s.ackWait.Store(int64(ackWait))
+	}
+	if maxDeliver != 0 {
+		s.maxDeliver.Store(int64(maxDeliver))
+	}
+}
+
+// lastDelivery 判斷消息是否已達到最大投遞次數
+func (s *subscriptionState) lastDelivery(msg jsMessage) bool {
+	maxDeliver := s.maxDeliver.Load()
+	if maxDeliver <= 0 {
+		return false
+	}
+	meta, err := msg.Metadata()
+	if err != nil {
+		return false
+	}
+	return int64(meta.NumDelivered) >= maxDeliver
+}
+
+// handleMessage 執行 handle 並根據結果確認、延遲重投或終止消息
+// handle 的 ctx 派生自 ctx，帶有消息頭中的追蹤上下文，並在 HandlerTimeout (默認為 AckWait) 後超時
+// handler 運行期間定期發送 InProgress，避免長時間任務超過 AckWait 後被重新投遞
+// 開啟死信時，終止的消息和最後一次投遞仍失敗的消息會被轉發到死信主題
+func (m *jetStreamNatsManager) handleMessage(
+	ctx context.Context,
+	state *subscriptionState,
+	msg jsMessage,
+	handle func(ctx context.Context) error) error {
+
+	subject := state.subject
+	ackWait := time.Duration(state.ackWait.Load())
+
+	timeout := m.config.HandlerTimeout
+	if timeout <= 0 {
+		timeout = ackWait
+	}
+	duration, err := m.runHandler(ExtractTrace(ctx, msg.Headers()), subject, msg, ackWait, timeout, handle)
+
+	if err != nil && m.config.DeadLetter.Enabled &&
+		(errors.Is(err, ErrPermanent) || state.lastDelivery(msg)) {
+		if dlqErr := m.deadLetter(ctx, msg, err); dlqErr != nil {
+			m.logger.Error("failed to dead letter message",
+				zap.Error(dlqErr),
+				zap.String("subject", subject))
+			m.nakMessage(subject, msg)
+			m.metrics.handled(subject, duration, OutcomeNak)
+			return err
+		}
+		m.metrics.handled(subject, duration, OutcomeDeadLetter)
+		m.logger.Warn("message moved to dead letter subject",
+			zap.Error(err),
+			zap.String("subject", subject))
+		if termErr := msg.Term(); termErr != nil {
+			m.logger.Error("failed to terminate message",
+				zap.Error(termErr),
+				zap.String("subject", subject))
+		}
+		return err
+	}
+
+	switch {
+	case err == nil:
+		m.metrics.handled(subject, duration, OutcomeAck)
+		if ackErr := msg.Ack(); ackErr != nil {
+			m.logger.Error("failed to ack message",
+				zap.Error(ackErr),
+				zap.String("subject", subject))
+		}
+	case errors.Is(err, ErrPermanent):
+		m.metrics.handled(subject, duration, OutcomeTerm)
+		m.logger.Error("failed to handle message, terminating",
+			zap.Error(err),
+			zap.String("subject", subject))
+		if termErr := msg.Term(); termErr != nil {
+			m.logger.Error("failed to terminate message",
+				zap.Error(termErr),
+				zap.String("subject", subject))
+		}
+	default:
+		m.metrics.handled(subject, duration, OutcomeNak)
+		m.logger.Error("failed to handle message",
+			zap.Error(err),
+			zap.String("subject", subject))
+		m.nakMessage(subject, msg)
+	}
+
+	return err
+}
+
+// runHandler 在超時時間內執行 handle，返回耗時和結果
+// handle 發生 panic 時轉換為錯誤，使消息按正常流程重新投遞或轉入死信，並確保 InProgress 停止
+func (m *jetStreamNatsManager) runHandler(
+	ctx context.Context,
+	subject string,
+	msg jsMessage,
+	ackWait, timeout time.Duration,
+	handle func(ctx context.Context) error) (duration time.Duration, err error) {
+
+	handlerCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stop := m.keepInProgress(subject, msg, ackWait)
+	defer stop()
+
+	start := time.Now()
+	defer func() {
+		duration = time.Since(start)
+		if r := recover(); r != nil {
+			m.logger.Error("message handler panicked",
+				zap.Any("panic", r),
+				zap.String("subject", subject),
+				zap.Stack("stack"))
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+
+	err = handle(handlerCtx)
+	return duration, err
+}
+
+// nakMessage 按投遞次數指數延遲後重新投遞消息
+func (m *jetStreamNatsManager) nakMessage(subject string, msg jsMessage) {
+	delay := m.nakDelay(msg)
+	if err := msg.NakWithDelay(delay); err != nil {
+		m.logger.Error("failed to nak message",
+			zap.Error(err),
+			zap.String("subject", subject))
+	}
+}
+
+func (m *jetStreamNatsManager) nakDelay(msg jsMessage) time.Duration {
+	delay := m.config.NakDelay
+	if delay <= 0 {
+		return 0
+	}
+
+	meta, err := msg.Metadata()
+	if err != nil || meta.NumDelivered <= 1 {
+		return delay
+	}
+
+	for i := uint64(1); i < meta.NumDelivered && delay < maxNakDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxNakDelay)
+}
+
+// keepInProgress 在 handler 運行期間每半個 AckWait 發送一次 InProgress，返回停止函數
+func (m *jetStreamNatsManager) keepInProgress(subject string, msg jsMessage, ackWait time.Duration) func() {
+	if ackWait <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(ackWait / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := msg.InProgress(); err != nil {
+					m.logger.Warn("failed to extend ack deadline",
+						zap.Error(err),
+						zap.String("subject", subject))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
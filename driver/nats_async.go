@@ -0,0 +1,129 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"go.uber.org/zap"
+)
+
+// defaultAsyncMaxPending 是未確認的異步發布數量上限
+const defaultAsyncMaxPending = 4000
+
+// PublishFuture 表示一次異步發布的結果
+type PublishFuture interface {
+	// Ok 在發布成功時收到服務器的確認
+	Ok() <-chan *jetstream.PubAck
+
+	// Err 在發布失敗時收到錯誤
+	Err() <-chan error
+
+	// Wait 等待發布結果，直到收到確認、失敗或 ctx 被取消
+	Wait(ctx context.Context) (*jetstream.PubAck, error)
+}
+
+// AsyncPublishError 描述一條異步發布失敗的消息
+type AsyncPublishError struct {
+	Subject string
+	Err     error
+}
+
+func (e *AsyncPublishError) Error() string {
+	return fmt.Sprintf("async publish to %s failed: %v", e.Subject, e.Err)
+}
+
+func (e *AsyncPublishError) Unwrap() error { return e.Err }
+
+type publishFuture struct {
+	jetstream.PubAckFuture
+}
+
+func (f publishFuture) Wait(ctx context.Context) (*jetstream.PubAck, error) {
+	select {
+	case ack := <-f.Ok():
+		return ack, nil
+	case err := <-f.Err():
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// asyncErrors 收集異步發布的失敗，直到下一次 Flush
+type asyncErrors struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (a *asyncErrors) add(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.errs = append(a.errs, err)
+}
+
+func (a *asyncErrors) drain() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	err := errors.Join(a.errs...)
+	a.errs = nil
+	return err
+}
+
+// asyncErrHandler 記錄異步發布的失敗
+func (m *jetStreamNatsManager) asyncErrHandler(_ jetstream.JetStream, msg *nats.Msg, err error) {
+	m.logger.Error("async publish failed",
+		zap.Error(err),
+		zap.String("subject", msg.Subject))
+	m.metrics.asyncFailed(msg.Subject)
+	m.asyncErrs.add(&AsyncPublishError{Subject: msg.Subject, Err: err})
+}
+
+// PublishAsync 異步發布消息，不等待服務器確認
+// 未確認的消息數達到 AsyncMaxPending 時會短暫阻塞，仍無空位則返回錯誤
+func (m *jetStreamNatsManager) PublishAsync(ctx context.Context, subject string, data []byte) (PublishFuture, error) {
+	return m.publishMsgAsync(ctx, &nats.Msg{Subject: subject, Data: data})
+}
+
+// PublishMsgAsync 異步發布事件
+func (m *jetStreamNatsManager) PublishMsgAsync(ctx context.Context, event Event) (PublishFuture, error) {
+	if event.Subject == "" {
+		return nil, fmt.Errorf("event subject is required")
+	}
+	if event.Source == "" {
+		event.Source = m.eventSource()
+	}
+
+	msg, err := event.toMsg()
+	if err != nil {
+		return nil, err
+	}
+	return m.publishMsgAsync(ctx, msg)
+}
+
+func (m *jetStreamNatsManager) publishMsgAsync(ctx context.Context, msg *nats.Msg) (PublishFuture, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("context cancelled: %w", err)
+	}
+
+	InjectTrace(ctx, msg)
+	future, err := m.jetStream.PublishMsgAsync(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish async to %s: %w", msg.Subject, err)
+	}
+	return publishFuture{future}, nil
+}
+
+// Flush 等待所有異步發布完成，返回自上次 Flush 以來所有失敗的匯總
+func (m *jetStreamNatsManager) Flush(ctx context.Context) error {
+	select {
+	case <-m.jetStream.PublishAsyncComplete():
+	case <-ctx.Done():
+		return fmt.Errorf("flush interrupted with %d pending: %w", m.jetStream.PublishAsyncPending(), ctx.Err())
+	}
+	return m.asyncErrs.drain()
+}
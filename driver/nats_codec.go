@@ -0,0 +1,83 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentTypeHeader 是標識消息編碼格式的消息頭
+const ContentTypeHeader = "Content-Type"
+
+// Codec 定義消息的編碼格式
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	ContentType() string
+}
+
+// JSONCodec 使用 encoding/json 編碼
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (JSONCodec) ContentType() string                { return "application/json" }
+
+// ProtoCodec 使用 protobuf 編碼，值必須實現 proto.Message
+type ProtoCodec struct{}
+
+func (ProtoCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf codec: %T does not implement proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (ProtoCodec) Unmarshal(data []byte, v any) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+
+	// 支持 **T 形式的目標，例如泛型函數中 var resp *pb.Reply; Unmarshal(data, &resp)
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() && rv.Elem().Kind() == reflect.Pointer {
+		if rv.Elem().IsNil() {
+			rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+		}
+		if m, ok := rv.Elem().Interface().(proto.Message); ok {
+			return proto.Unmarshal(data, m)
+		}
+	}
+	return fmt.Errorf("protobuf codec: %T does not implement proto.Message", v)
+}
+
+func (ProtoCodec) ContentType() string { return "application/protobuf" }
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		JSONCodec{}.ContentType():  JSONCodec{},
+		ProtoCodec{}.ContentType(): ProtoCodec{},
+	}
+)
+
+// RegisterCodec 註冊自定義編碼格式，接收方按 Content-Type 消息頭選擇編碼
+func RegisterCodec(codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[codec.ContentType()] = codec
+}
+
+// CodecFor 返回 contentType 對應的編碼格式，未知或為空時返回 JSONCodec
+func CodecFor(contentType string) Codec {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	if codec, ok := codecs[contentType]; ok {
+		return codec
+	}
+	return JSONCodec{}
+}
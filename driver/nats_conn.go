@@ -0,0 +1,300 @@
+package driver
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"go.uber.org/zap"
+)
+
+// NatsAuthConfig 定義連接 NATS 的認證方式，同時只應設置一種
+type NatsAuthConfig struct {
+	User         string `yaml:"user"`
+	Password     string `yaml:"password"`
+	Token        string `yaml:"token"`
+	NKeySeedFile string `yaml:"nkey_seed_file"` // NKey 種子文件
+	CredsFile    string `yaml:"creds_file"`     // 包含 JWT 和 NKey 種子的憑證文件
+}
+
+// NatsTLSConfig 定義連接 NATS 的 TLS 配置
+type NatsTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	CertFile           string `yaml:"cert_file"` // 客戶端證書，與 KeyFile 一起使用
+	KeyFile            string `yaml:"key_file"`
+	CAFile             string `yaml:"ca_file"`     // 用於驗證服務器證書的 CA
+	ServerName         string `yaml:"server_name"` // 驗證服務器證書時使用的主機名
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// NatsReconnectConfig 定義連接斷開後的重連策略
+type NatsReconnectConfig struct {
+	MaxReconnects  int           `yaml:"max_reconnects"`  // 最大重連次數，-1 表示無限重連，0 使用客戶端默認值
+	Wait           time.Duration `yaml:"wait"`            // 每次重連之間的等待時間
+	Jitter         time.Duration `yaml:"jitter"`          // 重連等待的隨機抖動
+	JitterTLS      time.Duration `yaml:"jitter_tls"`      // TLS 連接的重連等待抖動
+	BufSize        int           `yaml:"buf_size"`        // 重連期間緩存的發布數據字節數
+	PingInterval   time.Duration `yaml:"ping_interval"`   // 心跳間隔
+	MaxPingsOut    int           `yaml:"max_pings_out"`   // 未響應的心跳達到該數量時視為斷開
+	ConnectTimeout time.Duration `yaml:"connect_timeout"` // 建立連接的超時時間
+}
+
+// NatsConnState 表示 NATS 連接的狀態
+type NatsConnState int32
+
+const (
+	NatsConnecting NatsConnState = iota
+	NatsConnected
+	NatsDisconnected
+	NatsClosed
+)
+
+func (s NatsConnState) String() string {
+	switch s {
+	case NatsConnecting:
+		return "connecting"
+	case NatsConnected:
+		return "connected"
+	case NatsDisconnected:
+		return "disconnected"
+	case NatsClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// NatsConnHealth 記錄連接事件，供健康檢查使用
+type NatsConnHealth struct {
+	state       atomic.Int32
+	disconnects atomic.Uint64
+	reconnects  atomic.Uint64
+
+	mu        sync.RWMutex
+	lastErr   error
+	changedAt time.Time
+}
+
+// NewNatsConnHealth 創建連接狀態，初始為 connecting
+func NewNatsConnHealth() *NatsConnHealth {
+	h := &NatsConnHealth{}
+	h.set(NatsConnecting, nil)
+	return h
+}
+
+func (h *NatsConnHealth) set(state NatsConnState, err error) {
+	h.state.Store(int32(state))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.changedAt = time.Now()
+	if err != nil {
+		h.lastErr = err
+	}
+}
+
+// State 返回當前連接狀態
+func (h *NatsConnHealth) State() NatsConnState {
+	return NatsConnState(h.state.Load())
+}
+
+// Disconnects 返回斷開連接的次數
+func (h *NatsConnHealth) Disconnects() uint64 {
+	return h.disconnects.Load()
+}
+
+// Reconnects 返回重連成功的次數
+func (h *NatsConnHealth) Reconnects() uint64 {
+	return h.reconnects.Load()
+}
+
+// LastError 返回最近一次連接錯誤
+func (h *NatsConnHealth) LastError() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.lastErr
+}
+
+// Check 在連接不可用時返回錯誤
+func (h *NatsConnHealth) Check() error {
+	state := h.State()
+	if state == NatsConnected {
+		return nil
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.lastErr != nil {
+		return fmt.Errorf("nats %s since %s: %w", state, h.changedAt.Format(time.RFC3339), h.lastErr)
+	}
+	return fmt.Errorf("nats %s since %s", state, h.changedAt.Format(time.RFC3339))
+}
+
+// ConnectNats 按配置的認證、TLS 和重連選項連接 NATS
+// 連接事件通過 logger 記錄，並更新 health (可以為 nil)
+func ConnectNats(config NatsConfig, logger *zap.Logger, health *NatsConnHealth) (*nats.Conn, error) {
+	opts, err := config.connectOptions(logger, health)
+	if err != nil {
+		return nil, err
+	}
+
+	nc, err := nats.Connect(config.URL, opts...)
+	if err != nil {
+		if health != nil {
+			health.set(NatsDisconnected, err)
+		}
+		return nil, err
+	}
+
+	if health != nil {
+		health.set(NatsConnected, nil)
+	}
+	return nc, nil
+}
+
+// connectOptions 將配置轉換為 nats.Option
+func (c NatsConfig) connectOptions(logger *zap.Logger, health *NatsConnHealth) ([]nats.Option, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if health == nil {
+		health = NewNatsConnHealth()
+	}
+
+	name := c.Name
+	if name == "" {
+		name = c.StreamName
+	}
+
+	opts := []nats.Option{nats.Name(name)}
+
+	authOpts, err := c.Auth.options()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, authOpts...)
+
+	tlsOpts, err := c.TLS.options()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, tlsOpts...)
+
+	opts = append(opts, c.Reconnect.options()...)
+
+	logger = logger.With(zap.String("component", "nats"))
+	opts = append(opts,
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			health.disconnects.Add(1)
+			health.set(NatsDisconnected, err)
+			logger.Warn("nats disconnected",
+				zap.Error(err),
+				zap.String("url", nc.ConnectedUrlRedacted()))
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			health.reconnects.Add(1)
+			health.set(NatsConnected, nil)
+			logger.Info("nats reconnected",
+				zap.String("url", nc.ConnectedUrlRedacted()),
+				zap.Uint64("reconnects", nc.Stats().Reconnects))
+		}),
+		nats.ClosedHandler(func(nc *nats.Conn) {
+			health.set(NatsClosed, nc.LastError())
+			logger.Info("nats connection closed", zap.Error(nc.LastError()))
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, sub *nats.Subscription, err error) {
+			fields := []zap.Field{zap.Error(err)}
+			if sub != nil {
+				fields = append(fields, zap.String("subject", sub.Subject))
+			}
+			logger.Error("nats async error", fields...)
+		}),
+	)
+
+	return opts, nil
+}
+
+func (c NatsAuthConfig) options() ([]nats.Option, error) {
+	var opts []nats.Option
+
+	set := 0
+	if c.User != "" {
+		opts = append(opts, nats.UserInfo(c.User, c.Password))
+		set++
+	}
+	if c.Token != "" {
+		opts = append(opts, nats.Token(c.Token))
+		set++
+	}
+	if c.NKeySeedFile != "" {
+		opt, err := nats.NkeyOptionFromSeed(c.NKeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load nkey seed: %w", err)
+		}
+		opts = append(opts, opt)
+		set++
+	}
+	if c.CredsFile != "" {
+		opts = append(opts, nats.UserCredentials(c.CredsFile))
+		set++
+	}
+
+	if set > 1 {
+		return nil, errors.New("only one of user, token, nkey_seed_file and creds_file may be set")
+	}
+	return opts, nil
+}
+
+func (c NatsTLSConfig) options() ([]nats.Option, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, errors.New("tls cert_file and key_file must be set together")
+	}
+
+	opts := []nats.Option{nats.Secure(&tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	})}
+	if c.CAFile != "" {
+		opts = append(opts, nats.RootCAs(c.CAFile))
+	}
+	if c.CertFile != "" {
+		opts = append(opts, nats.ClientCert(c.CertFile, c.KeyFile))
+	}
+	return opts, nil
+}
+
+func (c NatsReconnectConfig) options() []nats.Option {
+	var opts []nats.Option
+	if c.MaxReconnects != 0 {
+		opts = append(opts, nats.MaxReconnects(c.MaxReconnects))
+	}
+	if c.Wait > 0 {
+		opts = append(opts, nats.ReconnectWait(c.Wait))
+	}
+	if c.Jitter > 0 || c.JitterTLS > 0 {
+		opts = append(opts, nats.ReconnectJitter(c.Jitter, c.JitterTLS))
+	}
+	if c.BufSize != 0 {
+		opts = append(opts, nats.ReconnectBufSize(c.BufSize))
+	}
+	if c.PingInterval > 0 {
+		opts = append(opts, nats.PingInterval(c.PingInterval))
+	}
+	if c.MaxPingsOut > 0 {
+		opts = append(opts, nats.MaxPingsOutstanding(c.MaxPingsOut))
+	}
+	if c.ConnectTimeout > 0 {
+		opts = append(opts, nats.Timeout(c.ConnectTimeout))
+	}
+	return opts
+}
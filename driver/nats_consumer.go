@@ -0,0 +1,244 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"go.uber.org/zap"
+)
+
+// durableNameReplacer 將主題中不能用於消費者名稱的字符替換掉
+var durableNameReplacer = strings.NewReplacer(".", "_", "*", "ANY", ">", "ALL", " ", "_")
+
+// pullDurableSuffix 是拉取消費者持久名稱的後綴
+// 推送和拉取消費者不能共用，同一主題的推送訂閱和拉取訂閱需要綁定不同的 JetStream 消費者
+const pullDurableSuffix = "_pull"
+
+// SubscribeDurable 創建持久的推送訂閱，消費者名稱由 DurablePrefix 和主題推導
+// 服務重啟後會從上次確認的位置繼續消費
+func (m *jetStreamNatsManager) SubscribeDurable(subject string, handler NatsHandler, opts ...nats.SubOpt) (*nats.Subscription, error) {
+	state := newSubscriptionState(subject)
+
+	allOpts := m.getSubscriptionNatsOption(append([]nats.SubOpt{nats.Durable(m.getDurableName(subject))}, opts...)...)
+
+	sub, err := m.js.Subscribe(subject, m.msgHandler(state, handler), allOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe durable: %w", err)
+	}
+
+	m.trackSubscription(sub, state)
+	return sub, nil
+}
+
+// QueueSubscribe 創建隊列組推送訂閱，同一隊列組的多個副本共享同一個持久消費者，每條消息只投遞給其中一個
+// 持久消費者按隊列組和主題命名，同一隊列組可以訂閱多個主題
+func (m *jetStreamNatsManager) QueueSubscribe(subject, queue string, handler NatsHandler, opts ...nats.SubOpt) (*nats.Subscription, error) {
+	if queue == "" {
+		return nil, fmt.Errorf("queue subscribe: empty queue name")
+	}
+
+	state := newSubscriptionState(subject)
+
+	allOpts := m.getSubscriptionNatsOption(append([]nats.SubOpt{nats.Durable(m.getDurableName(queue + "." + subject))}, opts...)...)
+
+	sub, err := m.js.QueueSubscribe(subject, queue, m.msgHandler(state, handler), allOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to queue subscribe: %w", err)
+	}
+
+	m.trackSubscription(sub, state)
+	return sub, nil
+}
+
+// PullSubscribe 創建持久的拉取訂閱，並在後台按批次拉取消息交給 worker pool 處理
+// 同時處理中的消息數不超過 worker pool 的容量；取消訂閱或關閉管理器時停止拉取
+// 持久消費者按主題命名並加上 _pull 後綴，與同一主題的 SubscribeDurable 互不影響
+func (m *jetStreamNatsManager) PullSubscribe(subject string, handler NatsHandler, opts ...nats.SubOpt) (*nats.Subscription, error) {
+	state := newSubscriptionState(subject)
+
+	sub, err := m.js.PullSubscribe(subject, m.getDurableName(subject)+pullDurableSuffix, m.getSubscriptionNatsOption(opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull subscribe: %w", err)
+	}
+
+	m.trackSubscription(sub, state)
+
+	go m.pullLoop(sub, state, m.applyMiddlewares(handler))
+	return sub, nil
+}
+
+// pullLoop 持續拉取消息，直到訂閱失效或管理器關閉
+func (m *jetStreamNatsManager) pullLoop(sub *nats.Subscription, state *subscriptionState, handler NatsHandler) {
+	logger := m.logger.With(zap.String("subject", state.subject))
+
+	batchSize := max(m.config.PullBatchSize, 1)
+	maxWait := m.config.PullMaxWait
+	if maxWait <= 0 {
+		maxWait = defaultAckWait
+	}
+
+	// inFlight 限制同時處理中的消息數
+	inFlight := make(chan struct{}, max(m.pool.Cap(), 1))
+
+	for attempt := 0; ; {
+		// 至少等到一個空位，再盡量多佔用空位，最多 batchSize 個
+		select {
+		case <-m.ctx.Done():
+			return
+		case inFlight <- struct{}{}:
+		}
+		slots := 1
+	acquire:
+		for slots < batchSize {
+			select {
+			case inFlight <- struct{}{}:
+				slots++
+			default:
+				break acquire
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(m.ctx, maxWait)
+		msgs, err := sub.Fetch(slots, nats.Context(ctx))
+		cancel()
+
+		// 歸還未使用的空位
+		for i := len(msgs); i < slots; i++ {
+			<-inFlight
+		}
+
+		for _, msg := range msgs {
+			if err := m.pool.Submit(context.Background(), func() error {
+				defer func() { <-inFlight }()
+				return m.handleMessage(m.ctx, state, legacyMsg{msg}, func(ctx context.Context) error {
+					return handler(ctx, msg)
+				})
+			}); err != nil {
+				<-inFlight
+				logger.Error("failed to submit message to worker pool", zap.Error(err))
+				m.nakMessage(state.subject, legacyMsg{msg})
+			}
+		}
+
+		if err == nil {
+			attempt = 0
+			continue
+		}
+
+		switch {
+		case m.ctx.Err() != nil:
+			return
+		case errors.Is(err, context.DeadlineExceeded), errors.Is(err, nats.ErrTimeout):
+			continue
+		case errors.Is(err, nats.ErrBadSubscription), errors.Is(err, nats.ErrConnectionClosed):
+			logger.Info("pull subscription closed, stopping")
+			return
+		}
+
+		backoff := RetryConfig{}.Backoff(attempt)
+		logger.Warn("failed to fetch messages, retrying",
+			zap.Error(err),
+			zap.Duration("backoff", backoff))
+		attempt++
+		if sleepContext(m.ctx, backoff) != nil {
+			return
+		}
+	}
+}
+
+// Consume 在 stream 上創建或更新 config 描述的消費者，並通過 worker pool 處理消息
+// 同時拉取的消息數不超過 worker pool 的容量；調用返回值的 Stop 停止消費
+// 未設置 Durable 和 Name 時按 FilterSubject 命名持久消費者，與 PullSubscribe 一樣加上 _pull 後綴
+func (m *jetStreamNatsManager) Consume(
+	ctx context.Context,
+	stream string,
+	config jetstream.ConsumerConfig,
+	handler JetStreamHandler) (jetstream.ConsumeContext, error) {
+
+	if config.AckWait == 0 {
+		config.AckWait = defaultAckWait
+	}
+	if config.MaxDeliver == 0 {
+		config.MaxDeliver = defaultMaxDeliver
+	}
+	if config.Durable == "" && config.Name == "" && config.FilterSubject != "" {
+		config.Durable = m.getDurableName(config.FilterSubject) + pullDurableSuffix
+	}
+
+	consumer, err := m.jetStream.CreateOrUpdateConsumer(ctx, stream, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+
+	return m.consume(consumer, config.FilterSubject, handler)
+}
+
+// OrderedConsume 創建有序消費者按順序處理 stream 中的消息
+// 有序消費者不需要確認，消息依次處理以保證順序
+func (m *jetStreamNatsManager) OrderedConsume(
+	ctx context.Context,
+	stream string,
+	config jetstream.OrderedConsumerConfig,
+	handler JetStreamHandler) (jetstream.ConsumeContext, error) {
+
+	consumer, err := m.jetStream.OrderedConsumer(ctx, stream, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ordered consumer: %w", err)
+	}
+
+	logger := m.logger.With(zap.String("stream", stream))
+	cc, err := consumer.Consume(func(msg jetstream.Msg) {
+		if err := handler(ExtractTrace(m.ctx, msg.Headers()), msg); err != nil {
+			logger.Error("failed to handle message",
+				zap.Error(err),
+				zap.String("subject", msg.Subject()))
+		}
+	}, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+		logger.Warn("ordered consumer error", zap.Error(err))
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume: %w", err)
+	}
+	return cc, nil
+}
+
+// JetStream 返回底層的 jetstream 實例，用於高級的 stream 和消費者管理
+func (m *jetStreamNatsManager) JetStream() jetstream.JetStream {
+	return m.jetStream
+}
+
+func (m *jetStreamNatsManager) consume(consumer jetstream.Consumer, subject string, handler JetStreamHandler) (jetstream.ConsumeContext, error) {
+	state := newSubscriptionState(subject)
+	if info := consumer.CachedInfo(); info != nil {
+		state.update(info.Config.AckWait, info.Config.MaxDeliver)
+		if subject == "" {
+			state.subject = info.Name
+		}
+	}
+
+	logger := m.logger.With(zap.String("subject", state.subject))
+
+	cc, err := consumer.Consume(func(msg jetstream.Msg) {
+		if err := m.pool.Submit(context.Background(), func() error {
+			return m.handleMessage(m.ctx, state, msg, func(ctx context.Context) error {
+				return handler(ctx, msg)
+			})
+		}); err != nil {
+			logger.Error("failed to submit message to worker pool", zap.Error(err))
+			m.nakMessage(state.subject, msg)
+		}
+	},
+		jetstream.PullMaxMessages(max(m.pool.Cap(), 1)),
+		jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+			logger.Warn("consumer error", zap.Error(err))
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume: %w", err)
+	}
+	return cc, nil
+}
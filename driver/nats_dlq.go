@@ -0,0 +1,223 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"go.uber.org/zap"
+)
+
+// 死信消息攜帶的消息頭
+const (
+	DLQSubjectHeader    = "Nexus-DLQ-Subject"    // 原始主題
+	DLQStreamHeader     = "Nexus-DLQ-Stream"     // 原始 stream
+	DLQSequenceHeader   = "Nexus-DLQ-Sequence"   // 原始 stream 序列號
+	DLQDeliveriesHeader = "Nexus-DLQ-Deliveries" // 已投遞次數
+	DLQReasonHeader     = "Nexus-DLQ-Reason"     // 最後一次處理失敗的原因
+	DLQTimeHeader       = "Nexus-DLQ-Time"       // 進入死信的時間
+)
+
+// DeadLetterConfig 定義死信配置
+// 死信消息存放在 <StreamName>_DLQ stream 的 <StreamName>.DLQ.> 主題下，其他 stream 的主題不能與之重疊
+type DeadLetterConfig struct {
+	Enabled  bool          `yaml:"enabled"`   // 是否開啟死信
+	MaxAge   time.Duration `yaml:"max_age"`   // 死信消息的保留時間
+	MaxMsgs  int64         `yaml:"max_msgs"`  // 死信 stream 的最大消息數
+	MaxBytes int64         `yaml:"max_bytes"` // 死信 stream 的最大字節數
+}
+
+// ErrDeadLetterOverlap 表示死信主題被其他 stream 的主題覆蓋，死信會回到原 stream 而不是死信 stream
+var ErrDeadLetterOverlap = errors.New("dead letter subject overlaps stream subjects")
+
+// DLQFilter 決定一條死信消息是否需要重放，為 nil 時重放全部消息
+type DLQFilter func(msg jetstream.Msg) bool
+
+// deadLetterSubject 返回 subject 對應的死信主題
+func (m *jetStreamNatsManager) deadLetterSubject(subject string) string {
+	return fmt.Sprintf("%s.DLQ.%s", m.config.StreamName, subject)
+}
+
+func (m *jetStreamNatsManager) deadLetterStreamName() string {
+	return m.config.StreamName + "_DLQ"
+}
+
+// checkDeadLetterSubjects 檢查死信主題沒有被配置中的 stream 覆蓋
+func (m *jetStreamNatsManager) checkDeadLetterSubjects() error {
+	return m.config.checkReservedSubject(m.deadLetterSubject(">"), ErrDeadLetterOverlap)
+}
+
+// subjectsOverlap 判斷兩個可能包含通配符的主題是否能匹配同一個消息主題
+func subjectsOverlap(a, b string) bool {
+	at, bt := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(at) && i < len(bt); i++ {
+		if at[i] == ">" || bt[i] == ">" {
+			return true
+		}
+		if at[i] != bt[i] && at[i] != "*" && bt[i] != "*" {
+			return false
+		}
+	}
+	return len(at) == len(bt)
+}
+
+func (m *jetStreamNatsManager) setupDeadLetterStream(ctx context.Context) error {
+	if err := m.checkDeadLetterSubjects(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	config := jetstream.StreamConfig{
+		Name:      m.deadLetterStreamName(),
+		Subjects:  []string{m.deadLetterSubject(">")},
+		Storage:   jetstream.FileStorage,
+		Retention: jetstream.LimitsPolicy,
+		MaxAge:    m.config.DeadLetter.MaxAge,
+		MaxMsgs:   m.config.DeadLetter.MaxMsgs,
+		MaxBytes:  m.config.DeadLetter.MaxBytes,
+	}
+	if config.MaxMsgs == 0 {
+		config.MaxMsgs = -1
+	}
+	if config.MaxBytes == 0 {
+		config.MaxBytes = -1
+	}
+
+	if err := m.createOrUpdateStream(ctx, config); err != nil {
+		return err
+	}
+
+	return m.requireStream(ctx, config.Name, ErrDeadLetterOverlap)
+}
+
+// deadLetter 將消息連同失敗原因轉發到死信主題
+func (m *jetStreamNatsManager) deadLetter(ctx context.Context, msg jsMessage, reason error) error {
+	headers := nats.Header{}
+	for k, v := range msg.Headers() {
+		headers[k] = append([]string(nil), v...)
+	}
+	// 死信 stream 不需要按原始消息 ID 去重
+	headers.Del(nats.MsgIdHdr)
+
+	headers.Set(DLQSubjectHeader, msg.Subject())
+	headers.Set(DLQReasonHeader, reason.Error())
+	headers.Set(DLQTimeHeader, time.Now().UTC().Format(time.RFC3339Nano))
+	if meta, err := msg.Metadata(); err == nil {
+		headers.Set(DLQStreamHeader, meta.Stream)
+		headers.Set(DLQSequenceHeader, strconv.FormatUint(meta.Sequence.Stream, 10))
+		headers.Set(DLQDeliveriesHeader, strconv.FormatUint(meta.NumDelivered, 10))
+	}
+
+	return m.publishMsg(ctx, &nats.Msg{
+		Subject: m.deadLetterSubject(msg.Subject()),
+		Data:    msg.Data(),
+		Header:  headers,
+	})
+}
+
+// ReplayDLQ 將 subject 的死信消息重新發布到原始主題，並從死信 stream 中刪除
+// subject 可以包含通配符，返回重放的消息數
+func (m *jetStreamNatsManager) ReplayDLQ(ctx context.Context, subject string, filter DLQFilter) (int, error) {
+	if !m.config.DeadLetter.Enabled {
+		return 0, fmt.Errorf("dead letter is not enabled")
+	}
+
+	stream, err := m.jetStream.Stream(ctx, m.deadLetterStreamName())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get dead letter stream: %w", err)
+	}
+
+	consumer, err := stream.OrderedConsumer(ctx, jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{m.deadLetterSubject(subject)},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create dead letter consumer: %w", err)
+	}
+
+	// 只重放開始時已存在的消息，避免與新進入死信的消息無限循環
+	info, err := consumer.Info(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get dead letter consumer info: %w", err)
+	}
+	if info.NumPending == 0 {
+		return 0, nil
+	}
+
+	iter, err := consumer.Messages()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read dead letter stream: %w", err)
+	}
+	defer iter.Stop()
+
+	// ctx 取消時停止迭代器，使 Next 返回
+	stop := context.AfterFunc(ctx, iter.Stop)
+	defer stop()
+
+	replayed := 0
+	for pending := info.NumPending; pending > 0; pending-- {
+		msg, err := iter.Next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return replayed, ctx.Err()
+			}
+			return replayed, fmt.Errorf("failed to read dead letter message: %w", err)
+		}
+
+		meta, err := msg.Metadata()
+		if err != nil {
+			return replayed, fmt.Errorf("failed to read dead letter metadata: %w", err)
+		}
+
+		if filter == nil || filter(msg) {
+			if err = m.replayDeadLetter(ctx, msg); err != nil {
+				return replayed, err
+			}
+			if err = stream.DeleteMsg(ctx, meta.Sequence.Stream); err != nil {
+				m.logger.Warn("failed to delete replayed dead letter message",
+					zap.Error(err),
+					zap.Uint64("sequence", meta.Sequence.Stream))
+			}
+			replayed++
+		}
+	}
+
+	return replayed, nil
+}
+
+func (m *jetStreamNatsManager) replayDeadLetter(ctx context.Context, msg jetstream.Msg) error {
+	target := msg.Headers().Get(DLQSubjectHeader)
+	if target == "" {
+		return fmt.Errorf("dead letter message has no %s header", DLQSubjectHeader)
+	}
+
+	headers := nats.Header{}
+	for k, v := range msg.Headers() {
+		headers[k] = append([]string(nil), v...)
+	}
+	for _, k := range []string{
+		DLQSubjectHeader,
+		DLQStreamHeader,
+		DLQSequenceHeader,
+		DLQDeliveriesHeader,
+		DLQReasonHeader,
+		DLQTimeHeader,
+	} {
+		headers.Del(k)
+	}
+
+	if err := m.publishMsg(ctx, &nats.Msg{Subject: target, Data: msg.Data(), Header: headers}); err != nil {
+		return fmt.Errorf("failed to replay dead letter message to %s: %w", target, err)
+	}
+
+	m.logger.Info("replayed dead letter message",
+		zap.String("subject", target))
+	return nil
+}
@@ -0,0 +1,60 @@
+package driver
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSubjectsOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"ORDERS.>", "ORDERS.DLQ.>", true},
+		{"ORDERS.*.>", "ORDERS.DLQ.>", true},
+		{"ORDERS.*", "ORDERS.DLQ.>", false},
+		{"ORDERS", "ORDERS.DLQ.>", false},
+		{">", "ORDERS.DLQ.>", true},
+		{"orders.created", "ORDERS.DLQ.>", false},
+		{"ORDERS.DLQ", "ORDERS.DLQ.>", false},
+		{"ORDERS.DLQ.a", "ORDERS.DLQ.>", true},
+		{"a.*.c", "a.b.*", true},
+		{"a.*.c", "a.b.d", false},
+	}
+	for _, tt := range tests {
+		if got := subjectsOverlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("subjectsOverlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := subjectsOverlap(tt.b, tt.a); got != tt.want {
+			t.Errorf("subjectsOverlap(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestCheckDeadLetterSubjects(t *testing.T) {
+	config := DefaultConfig("ORDERS")
+	config.Subjects = []string{"ORDERS.>"}
+	m := &jetStreamNatsManager{config: config}
+	if err := m.checkDeadLetterSubjects(); err == nil {
+		t.Fatal("expected overlap error for ORDERS.>")
+	}
+
+	config.Subjects = []string{"orders.>"}
+	m.config = config
+	if err := m.checkDeadLetterSubjects(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCheckScheduleSubjects(t *testing.T) {
+	config := DefaultConfig("ORDERS")
+	config.Subjects = []string{"ORDERS.*.>"}
+	if err := config.checkReservedSubject("ORDERS.SCHEDULE.>", ErrScheduleOverlap); !errors.Is(err, ErrScheduleOverlap) {
+		t.Fatalf("error = %v, want ErrScheduleOverlap", err)
+	}
+
+	config.Subjects = []string{"ORDERS.created", "orders.>"}
+	if err := config.checkReservedSubject("ORDERS.SCHEDULE.>", ErrScheduleOverlap); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
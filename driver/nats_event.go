@@ -0,0 +1,184 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+)
+
+// CloudEvents 綁定模式下的消息頭
+const (
+	EventSpecVersion = "1.0"
+
+	EventIDHeader          = "ce-id"
+	EventTypeHeader        = "ce-type"
+	EventSourceHeader      = "ce-source"
+	EventSpecVersionHeader = "ce-specversion"
+	EventTimeHeader        = "ce-time"
+	EventSchemaHeader      = "ce-dataschema"
+
+	// CorrelationIDHeader 用於串聯同一業務流程中的多個事件
+	CorrelationIDHeader = "Nexus-Correlation-Id"
+)
+
+// Event 是與 CloudEvents 兼容的事件信封
+// 元數據以消息頭傳遞，Data 作為消息體
+type Event struct {
+	ID            string      // 事件 ID，同時作為 JetStream 去重的 Nats-Msg-Id，為空時自動生成
+	Subject       string      // 發布的 NATS 主題
+	Type          string      // 事件類型，例如 order.created
+	Source        string      // 事件來源，為空時使用配置的 EventSource
+	Time          time.Time   // 事件發生時間，為空時使用當前時間
+	ContentType   string      // Data 的編碼格式，為空時使用 application/json
+	Schema        string      // Data 的 schema 或版本
+	CorrelationID string      // 關聯 ID
+	Headers       nats.Header // 其他自定義消息頭
+	Data          []byte
+}
+
+// TypedEvent 是解碼後的事件及其負載
+type TypedEvent[T any] struct {
+	Event
+	Payload T
+}
+
+// NewEvent 使用 codec 編碼 payload 並創建事件，codec 為 nil 時使用 JSONCodec
+func NewEvent(subject, eventType string, payload any, codec Codec) (Event, error) {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+
+	data, err := codec.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode event payload: %w", err)
+	}
+
+	return Event{
+		ID:          nuid.Next(),
+		Subject:     subject,
+		Type:        eventType,
+		Time:        time.Now().UTC(),
+		ContentType: codec.ContentType(),
+		Data:        data,
+	}, nil
+}
+
+// PublishMsg 發布事件，事件 ID 作為 Nats-Msg-Id 讓 JetStream 在去重窗口內丟棄重複發布
+func (m *jetStreamNatsManager) PublishMsg(ctx context.Context, event Event, opts ...PublishOption) error {
+	if event.Subject == "" {
+		return fmt.Errorf("event subject is required")
+	}
+	if event.Source == "" {
+		event.Source = m.eventSource()
+	}
+
+	msg, err := event.toMsg()
+	if err != nil {
+		return err
+	}
+	return m.publishMsg(ctx, msg, opts...)
+}
+
+func (m *jetStreamNatsManager) eventSource() string {
+	if m.config.EventSource != "" {
+		return m.config.EventSource
+	}
+	return m.config.StreamName
+}
+
+// toMsg 將事件轉換為 NATS 消息，並補全缺省字段
+func (e Event) toMsg() (*nats.Msg, error) {
+	if e.Type == "" {
+		return nil, fmt.Errorf("event type is required")
+	}
+	if e.ID == "" {
+		e.ID = nuid.Next()
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.ContentType == "" {
+		e.ContentType = JSONCodec{}.ContentType()
+	}
+
+	headers := nats.Header{}
+	for k, v := range e.Headers {
+		headers[k] = append([]string(nil), v...)
+	}
+
+	headers.Set(nats.MsgIdHdr, e.ID)
+	headers.Set(EventIDHeader, e.ID)
+	headers.Set(EventTypeHeader, e.Type)
+	headers.Set(EventSpecVersionHeader, EventSpecVersion)
+	headers.Set(EventTimeHeader, e.Time.UTC().Format(time.RFC3339Nano))
+	headers.Set(ContentTypeHeader, e.ContentType)
+	if e.Source != "" {
+		headers.Set(EventSourceHeader, e.Source)
+	}
+	if e.Schema != "" {
+		headers.Set(EventSchemaHeader, e.Schema)
+	}
+	if e.CorrelationID != "" {
+		headers.Set(CorrelationIDHeader, e.CorrelationID)
+	}
+
+	return &nats.Msg{Subject: e.Subject, Data: e.Data, Header: headers}, nil
+}
+
+// DecodeEvent 從 NATS 消息中還原事件信封
+func DecodeEvent(msg *nats.Msg) (Event, error) {
+	event := Event{
+		ID:            msg.Header.Get(EventIDHeader),
+		Subject:       msg.Subject,
+		Type:          msg.Header.Get(EventTypeHeader),
+		Source:        msg.Header.Get(EventSourceHeader),
+		ContentType:   msg.Header.Get(ContentTypeHeader),
+		Schema:        msg.Header.Get(EventSchemaHeader),
+		CorrelationID: msg.Header.Get(CorrelationIDHeader),
+		Headers:       msg.Header,
+		Data:          msg.Data,
+	}
+
+	if event.ID == "" {
+		event.ID = msg.Header.Get(nats.MsgIdHdr)
+	}
+	if event.Type == "" {
+		return event, fmt.Errorf("message on %s is not an event: missing %s header", msg.Subject, EventTypeHeader)
+	}
+
+	if t := msg.Header.Get(EventTimeHeader); t != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return event, fmt.Errorf("invalid event time %q: %w", t, err)
+		}
+		event.Time = parsed
+	}
+
+	return event, nil
+}
+
+// Subscribe 訂閱事件，解碼信封和負載後調用 handler
+// 無法解碼的消息視為不可重試的錯誤
+func Subscribe[T any](nm NatsManager, subject string, handler func(ctx context.Context, event TypedEvent[T]) error, opts ...nats.SubOpt) (*nats.Subscription, error) {
+	return nm.Subscribe(subject, EventHandler(handler), opts...)
+}
+
+// EventHandler 將強類型的事件處理函數轉換為 NatsHandler，可與 SubscribeDurable 等方法一起使用
+func EventHandler[T any](handler func(ctx context.Context, event TypedEvent[T]) error) NatsHandler {
+	return func(ctx context.Context, msg *nats.Msg) error {
+		event, err := DecodeEvent(msg)
+		if err != nil {
+			return Permanent(err)
+		}
+
+		typed := TypedEvent[T]{Event: event}
+		if err = CodecFor(event.ContentType).Unmarshal(event.Data, &typed.Payload); err != nil {
+			return Permanent(fmt.Errorf("failed to decode %s payload: %w", event.Type, err))
+		}
+
+		return handler(ctx, typed)
+	}
+}
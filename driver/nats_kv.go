@@ -0,0 +1,320 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"go.uber.org/zap"
+)
+
+// KeyValueConfig 定義一個 JetStream KV bucket 的配置
+type KeyValueConfig struct {
+	Bucket       string        `yaml:"bucket"`
+	Description  string        `yaml:"description"`
+	History      uint8         `yaml:"history"`        // 每個鍵保留的歷史版本數，默認 1
+	TTL          time.Duration `yaml:"ttl"`            // 鍵的過期時間，0 表示不過期
+	MaxBytes     int64         `yaml:"max_bytes"`      // bucket 的最大字節數，0 表示不限制
+	MaxValueSize int32         `yaml:"max_value_size"` // 單個值的最大字節數，0 表示不限制
+	Storage      string        `yaml:"storage"`        // file 或 memory，默認 file
+	Replicas     int           `yaml:"replicas"`       // 集群中的副本數，默認 1
+}
+
+// ObjectStoreConfig 定義一個 JetStream Object Store bucket 的配置
+type ObjectStoreConfig struct {
+	Bucket      string        `yaml:"bucket"`
+	Description string        `yaml:"description"`
+	TTL         time.Duration `yaml:"ttl"`       // 對象的過期時間，0 表示不過期
+	MaxBytes    int64         `yaml:"max_bytes"` // bucket 的最大字節數，0 表示不限制
+	Storage     string        `yaml:"storage"`   // file 或 memory，默認 file
+	Replicas    int           `yaml:"replicas"`  // 集群中的副本數，默認 1
+}
+
+func (c KeyValueConfig) toJetStream() (jetstream.KeyValueConfig, error) {
+	if c.Bucket == "" {
+		return jetstream.KeyValueConfig{}, errors.New("bucket name is required")
+	}
+	storage, err := parseStorage(c.Storage)
+	if err != nil {
+		return jetstream.KeyValueConfig{}, err
+	}
+
+	config := jetstream.KeyValueConfig{
+		Bucket:       c.Bucket,
+		Description:  c.Description,
+		History:      max(c.History, 1),
+		TTL:          c.TTL,
+		MaxBytes:     c.MaxBytes,
+		MaxValueSize: c.MaxValueSize,
+		Storage:      storage,
+		Replicas:     max(c.Replicas, 1),
+	}
+	if config.MaxBytes == 0 {
+		config.MaxBytes = -1
+	}
+	if config.MaxValueSize == 0 {
+		config.MaxValueSize = -1
+	}
+	return config, nil
+}
+
+func (c ObjectStoreConfig) toJetStream() (jetstream.ObjectStoreConfig, error) {
+	if c.Bucket == "" {
+		return jetstream.ObjectStoreConfig{}, errors.New("bucket name is required")
+	}
+	storage, err := parseStorage(c.Storage)
+	if err != nil {
+		return jetstream.ObjectStoreConfig{}, err
+	}
+
+	config := jetstream.ObjectStoreConfig{
+		Bucket:      c.Bucket,
+		Description: c.Description,
+		TTL:         c.TTL,
+		MaxBytes:    c.MaxBytes,
+		Storage:     storage,
+		Replicas:    max(c.Replicas, 1),
+	}
+	if config.MaxBytes == 0 {
+		config.MaxBytes = -1
+	}
+	return config, nil
+}
+
+// KeyValue 返回名為 bucket 的 KV bucket
+// config 為 nil 時只綁定已存在的 bucket，否則按配置創建或更新
+func (m *jetStreamNatsManager) KeyValue(ctx context.Context, bucket string, config *KeyValueConfig) (jetstream.KeyValue, error) {
+	if config == nil {
+		kv, err := m.jetStream.KeyValue(ctx, bucket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to bind key value bucket %s: %w", bucket, err)
+		}
+		return kv, nil
+	}
+
+	cfg := *config
+	cfg.Bucket = bucket
+	jsConfig, err := cfg.toJetStream()
+	if err != nil {
+		return nil, fmt.Errorf("key value bucket %q: %w", bucket, err)
+	}
+
+	kv, err := m.jetStream.CreateOrUpdateKeyValue(ctx, jsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key value bucket %s: %w", bucket, err)
+	}
+	return kv, nil
+}
+
+// ObjectStore 返回名為 bucket 的 Object Store
+// config 為 nil 時只綁定已存在的 bucket，否則按配置創建或更新
+func (m *jetStreamNatsManager) ObjectStore(ctx context.Context, bucket string, config *ObjectStoreConfig) (jetstream.ObjectStore, error) {
+	if config == nil {
+		store, err := m.jetStream.ObjectStore(ctx, bucket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to bind object store %s: %w", bucket, err)
+		}
+		return store, nil
+	}
+
+	cfg := *config
+	cfg.Bucket = bucket
+	jsConfig, err := cfg.toJetStream()
+	if err != nil {
+		return nil, fmt.Errorf("object store %q: %w", bucket, err)
+	}
+
+	store, err := m.jetStream.CreateOrUpdateObjectStore(ctx, jsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create object store %s: %w", bucket, err)
+	}
+	return store, nil
+}
+
+// setupBuckets 創建或更新配置中聲明的 KV bucket 和 Object Store
+func (m *jetStreamNatsManager) setupBuckets(ctx context.Context) error {
+	var errs []error
+
+	for _, kv := range m.config.KeyValues {
+		if _, err := m.KeyValue(ctx, kv.Bucket, &kv); err != nil {
+			m.logger.Warn("failed to set up key value bucket",
+				zap.Error(err),
+				zap.String("bucket", kv.Bucket))
+			errs = append(errs, err)
+		}
+	}
+
+	for _, store := range m.config.ObjectStores {
+		if _, err := m.ObjectStore(ctx, store.Bucket, &store); err != nil {
+			m.logger.Warn("failed to set up object store",
+				zap.Error(err),
+				zap.String("bucket", store.Bucket))
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// KVEntry 是解碼後的 KV 條目
+type KVEntry[T any] struct {
+	Key       string
+	Value     T
+	Revision  uint64
+	Created   time.Time
+	Operation jetstream.KeyValueOp
+	Err       error // 值無法解碼時的錯誤
+}
+
+// TypedKV 使用 codec 編解碼值的 KV bucket
+type TypedKV[T any] struct {
+	kv    jetstream.KeyValue
+	codec Codec
+}
+
+// NewTypedKV 包裝 kv，codec 為 nil 時使用 JSONCodec
+func NewTypedKV[T any](kv jetstream.KeyValue, codec Codec) *TypedKV[T] {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &TypedKV[T]{kv: kv, codec: codec}
+}
+
+// KV 返回底層的 KV bucket
+func (t *TypedKV[T]) KV() jetstream.KeyValue {
+	return t.kv
+}
+
+// Get 讀取並解碼 key 的最新值，鍵不存在時返回 jetstream.ErrKeyNotFound
+func (t *TypedKV[T]) Get(ctx context.Context, key string) (T, uint64, error) {
+	var value T
+
+	entry, err := t.kv.Get(ctx, key)
+	if err != nil {
+		return value, 0, err
+	}
+	if err = t.codec.Unmarshal(entry.Value(), &value); err != nil {
+		return value, entry.Revision(), fmt.Errorf("failed to decode %s: %w", key, err)
+	}
+	return value, entry.Revision(), nil
+}
+
+// Put 編碼並寫入 key，返回新的版本號
+func (t *TypedKV[T]) Put(ctx context.Context, key string, value T) (uint64, error) {
+	data, err := t.codec.Marshal(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	return t.kv.Put(ctx, key, data)
+}
+
+// Update 僅在 key 的當前版本為 revision 時寫入，用於樂觀併發控制
+func (t *TypedKV[T]) Update(ctx context.Context, key string, value T, revision uint64) (uint64, error) {
+	data, err := t.codec.Marshal(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	return t.kv.Update(ctx, key, data, revision)
+}
+
+// Delete 刪除 key
+func (t *TypedKV[T]) Delete(ctx context.Context, key string) error {
+	return t.kv.Delete(ctx, key)
+}
+
+// Watch 監聽匹配 keys 的變更，先發送當前值，再發送後續更新，直到 ctx 被取消
+// 刪除操作的條目 Value 為零值
+func (t *TypedKV[T]) Watch(ctx context.Context, keys string, opts ...jetstream.WatchOpt) (<-chan KVEntry[T], error) {
+	watcher, err := t.kv.Watch(ctx, keys, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %w", keys, err)
+	}
+
+	out := make(chan KVEntry[T])
+	go func() {
+		defer close(out)
+		defer watcher.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case entry, ok := <-watcher.Updates():
+				if !ok {
+					return
+				}
+				// nil 表示初始值已全部發送
+				if entry == nil {
+					continue
+				}
+
+				typed := KVEntry[T]{
+					Key:       entry.Key(),
+					Revision:  entry.Revision(),
+					Created:   entry.Created(),
+					Operation: entry.Operation(),
+				}
+				if entry.Operation() == jetstream.KeyValuePut {
+					typed.Err = t.codec.Unmarshal(entry.Value(), &typed.Value)
+				}
+
+				select {
+				case out <- typed:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// TypedObjectStore 使用 codec 編解碼對象的 Object Store
+type TypedObjectStore[T any] struct {
+	store jetstream.ObjectStore
+	codec Codec
+}
+
+// NewTypedObjectStore 包裝 store，codec 為 nil 時使用 JSONCodec
+func NewTypedObjectStore[T any](store jetstream.ObjectStore, codec Codec) *TypedObjectStore[T] {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &TypedObjectStore[T]{store: store, codec: codec}
+}
+
+// ObjectStore 返回底層的 Object Store
+func (t *TypedObjectStore[T]) ObjectStore() jetstream.ObjectStore {
+	return t.store
+}
+
+// Get 讀取並解碼對象，對象不存在時返回 jetstream.ErrObjectNotFound
+func (t *TypedObjectStore[T]) Get(ctx context.Context, name string) (T, error) {
+	var value T
+
+	data, err := t.store.GetBytes(ctx, name)
+	if err != nil {
+		return value, err
+	}
+	if err = t.codec.Unmarshal(data, &value); err != nil {
+		return value, fmt.Errorf("failed to decode object %s: %w", name, err)
+	}
+	return value, nil
+}
+
+// Put 編碼並寫入對象
+func (t *TypedObjectStore[T]) Put(ctx context.Context, name string, value T) (*jetstream.ObjectInfo, error) {
+	data, err := t.codec.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode object %s: %w", name, err)
+	}
+	return t.store.PutBytes(ctx, name, data)
+}
+
+// Delete 刪除對象
+func (t *TypedObjectStore[T]) Delete(ctx context.Context, name string) error {
+	return t.store.Delete(ctx, name)
+}
@@ -0,0 +1,293 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// DefaultLatencyBuckets 是發布延遲和處理耗時直方圖的默認桶上界 (秒)
+var DefaultLatencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// HandlerOutcome 是消息處理的結果
+type HandlerOutcome string
+
+const (
+	OutcomeAck        HandlerOutcome = "ack"         // 處理成功並確認
+	OutcomeNak        HandlerOutcome = "nak"         // 處理失敗，延遲後重新投遞
+	OutcomeTerm       HandlerOutcome = "term"        // 永久失敗，終止消息
+	OutcomeDeadLetter HandlerOutcome = "dead_letter" // 轉發到死信主題後終止
+)
+
+// Histogram 是直方圖的快照
+// Counts[i] 是耗時不超過 Buckets[i] 的累計次數，與 Prometheus 的桶語義一致
+type Histogram struct {
+	Buckets []float64 `json:"buckets"`
+	Counts  []uint64  `json:"counts"`
+	Count   uint64    `json:"count"`
+	Sum     float64   `json:"sum"` // 秒
+}
+
+// PublishMetrics 是單個主題的發布指標
+// 同步發布的每次嘗試都計入 Latency 和 Errors，異步發布只計入 Errors
+type PublishMetrics struct {
+	Published uint64    `json:"published"` // 成功發布的消息數
+	Errors    uint64    `json:"errors"`    // 失敗的發布嘗試數
+	Retries   uint64    `json:"retries"`   // 重試次數
+	Failures  uint64    `json:"failures"`  // 重試後仍失敗的消息數
+	Latency   Histogram `json:"latency"`
+}
+
+// HandlerMetrics 是單個訂閱主題的消息處理指標
+type HandlerMetrics struct {
+	Outcomes map[HandlerOutcome]uint64 `json:"outcomes"`
+	Duration Histogram                 `json:"duration"`
+}
+
+// StreamMetrics 是 stream 的狀態
+type StreamMetrics struct {
+	Name      string `json:"name"`
+	Messages  uint64 `json:"messages"`
+	Bytes     uint64 `json:"bytes"`
+	Consumers int    `json:"consumers"`
+}
+
+// ConsumerMetrics 是消費者的積壓狀態
+type ConsumerMetrics struct {
+	Stream      string `json:"stream"`
+	Name        string `json:"name"`
+	Pending     uint64 `json:"pending"`     // 尚未投遞的消息數
+	AckPending  int    `json:"ack_pending"` // 已投遞但未確認的消息數
+	Redelivered int    `json:"redelivered"` // 正在重新投遞的消息數
+	Waiting     int    `json:"waiting"`     // 等待中的拉取請求數
+}
+
+// NatsMetrics 是 NatsManager 的指標快照
+// Publish 和 Handlers 是進程內的累計值；Streams 和 Consumers 在獲取快照時從服務器讀取
+type NatsMetrics struct {
+	Publish   map[string]PublishMetrics `json:"publish"`
+	Handlers  map[string]HandlerMetrics `json:"handlers"`
+	Streams   []StreamMetrics           `json:"streams"`
+	Consumers []ConsumerMetrics         `json:"consumers"`
+	Workers   map[string]any            `json:"workers"`
+}
+
+// histogram 是固定桶的直方圖，調用方負責加鎖
+type histogram struct {
+	buckets []float64
+	counts  []uint64 // 非累計
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	v := d.Seconds()
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *histogram) snapshot() Histogram {
+	counts := make([]uint64, len(h.counts))
+	var cumulative uint64
+	for i, c := range h.counts {
+		cumulative += c
+		counts[i] = cumulative
+	}
+	return Histogram{
+		Buckets: append([]float64(nil), h.buckets...),
+		Counts:  counts,
+		Count:   h.count,
+		Sum:     h.sum,
+	}
+}
+
+type publishStats struct {
+	published, errors, retries, failures uint64
+	latency                              *histogram
+}
+
+type handlerStats struct {
+	outcomes map[HandlerOutcome]uint64
+	duration *histogram
+}
+
+// natsMetrics 按主題記錄發布和處理指標
+// 發布指標以消息主題為鍵，主題包含 ID 等高基數部分時會產生大量序列
+type natsMetrics struct {
+	mu       sync.Mutex
+	buckets  []float64
+	publish  map[string]*publishStats
+	handlers map[string]*handlerStats
+}
+
+func newNatsMetrics() *natsMetrics {
+	return &natsMetrics{
+		buckets:  DefaultLatencyBuckets,
+		publish:  make(map[string]*publishStats),
+		handlers: make(map[string]*handlerStats),
+	}
+}
+
+func (s *natsMetrics) publishStats(subject string) *publishStats {
+	stats, ok := s.publish[subject]
+	if !ok {
+		stats = &publishStats{latency: newHistogram(s.buckets)}
+		s.publish[subject] = stats
+	}
+	return stats
+}
+
+// published 記錄一次同步發布嘗試
+func (s *natsMetrics) published(subject string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.publishStats(subject)
+	stats.latency.observe(latency)
+	if err != nil {
+		stats.errors++
+	} else {
+		stats.published++
+	}
+}
+
+// asyncFailed 記錄一次異步發布失敗
+func (s *natsMetrics) asyncFailed(subject string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publishStats(subject).errors++
+}
+
+func (s *natsMetrics) retry(subject string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publishStats(subject).retries++
+}
+
+func (s *natsMetrics) failure(subject string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publishStats(subject).failures++
+}
+
+// handled 記錄一次消息處理的耗時和結果
+func (s *natsMetrics) handled(subject string, duration time.Duration, outcome HandlerOutcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats, ok := s.handlers[subject]
+	if !ok {
+		stats = &handlerStats{
+			outcomes: make(map[HandlerOutcome]uint64),
+			duration: newHistogram(s.buckets),
+		}
+		s.handlers[subject] = stats
+	}
+	stats.duration.observe(duration)
+	stats.outcomes[outcome]++
+}
+
+// snapshot 返回進程內指標的副本
+func (s *natsMetrics) snapshot() (map[string]PublishMetrics, map[string]HandlerMetrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	publish := make(map[string]PublishMetrics, len(s.publish))
+	for subject, stats := range s.publish {
+		publish[subject] = PublishMetrics{
+			Published: stats.published,
+			Errors:    stats.errors,
+			Retries:   stats.retries,
+			Failures:  stats.failures,
+			Latency:   stats.latency.snapshot(),
+		}
+	}
+
+	handlers := make(map[string]HandlerMetrics, len(s.handlers))
+	for subject, stats := range s.handlers {
+		outcomes := make(map[HandlerOutcome]uint64, len(stats.outcomes))
+		for k, v := range stats.outcomes {
+			outcomes[k] = v
+		}
+		handlers[subject] = HandlerMetrics{
+			Outcomes: outcomes,
+			Duration: stats.duration.snapshot(),
+		}
+	}
+	return publish, handlers
+}
+
+// Metrics 返回指標快照，包含配置中所有 stream 及其消費者的狀態
+// 讀取某個 stream 失敗時跳過該 stream，全部失敗時返回最後一個錯誤和進程內的指標
+func (m *jetStreamNatsManager) Metrics(ctx context.Context) (*NatsMetrics, error) {
+	publish, handlers := m.metrics.snapshot()
+	metrics := &NatsMetrics{
+		Publish:  publish,
+		Handlers: handlers,
+	}
+	if m.pool != nil {
+		metrics.Workers = m.pool.GetMetrics()
+	}
+
+	var lastErr error
+	configs := m.config.streamConfigs()
+	for _, config := range configs {
+		stream, err := m.jetStream.Stream(ctx, config.Name)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to get stream %s: %w", config.Name, err)
+			continue
+		}
+		info, err := stream.Info(ctx)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to get stream %s info: %w", config.Name, err)
+			continue
+		}
+		metrics.Streams = append(metrics.Streams, StreamMetrics{
+			Name:      info.Config.Name,
+			Messages:  info.State.Msgs,
+			Bytes:     info.State.Bytes,
+			Consumers: info.State.Consumers,
+		})
+
+		consumers, err := m.consumerMetrics(ctx, stream)
+		if err != nil {
+			lastErr = err
+		}
+		metrics.Consumers = append(metrics.Consumers, consumers...)
+	}
+
+	if len(metrics.Streams) == 0 && lastErr != nil {
+		return metrics, lastErr
+	}
+	return metrics, nil
+}
+
+func (m *jetStreamNatsManager) consumerMetrics(ctx context.Context, stream jetstream.Stream) ([]ConsumerMetrics, error) {
+	name := stream.CachedInfo().Config.Name
+	lister := stream.ListConsumers(ctx)
+
+	var consumers []ConsumerMetrics
+	for info := range lister.Info() {
+		consumers = append(consumers, ConsumerMetrics{
+			Stream:      name,
+			Name:        info.Name,
+			Pending:     info.NumPending,
+			AckPending:  info.NumAckPending,
+			Redelivered: info.NumRedelivered,
+			Waiting:     info.NumWaiting,
+		})
+	}
+	if err := lister.Err(); err != nil {
+		return consumers, fmt.Errorf("failed to list consumers of %s: %w", name, err)
+	}
+	return consumers, nil
+}
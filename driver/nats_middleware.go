@@ -0,0 +1,97 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"go.uber.org/zap"
+)
+
+// NatsMiddleware 包裝 NatsHandler，用於實現日誌、超時、恢復等通用邏輯
+type NatsMiddleware func(NatsHandler) NatsHandler
+
+// Use 註冊中間件，對之後創建的 Subscribe、SubscribeDurable、QueueSubscribe 和 PullSubscribe 訂閱生效
+// 先註冊的中間件位於外層，最先執行
+func (m *jetStreamNatsManager) Use(middlewares ...NatsMiddleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.middlewares = append(m.middlewares, middlewares...)
+}
+
+// applyMiddlewares 用已註冊的中間件包裝 handler
+func (m *jetStreamNatsManager) applyMiddlewares(handler NatsHandler) NatsHandler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return Chain(m.middlewares...)(handler)
+}
+
+// Chain 將多個中間件組合為一個，第一個位於最外層
+func Chain(middlewares ...NatsMiddleware) NatsMiddleware {
+	return func(handler NatsHandler) NatsHandler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			handler = middlewares[i](handler)
+		}
+		return handler
+	}
+}
+
+// TimeoutMiddleware 為每條消息的處理設置超時時間
+func TimeoutMiddleware(timeout time.Duration) NatsMiddleware {
+	return func(next NatsHandler) NatsHandler {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return next(ctx, msg)
+		}
+	}
+}
+
+// LoggingMiddleware 記錄每條消息的主題、序號、投遞次數、耗時和處理結果
+func LoggingMiddleware(logger *zap.Logger) NatsMiddleware {
+	return func(next NatsHandler) NatsHandler {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			start := time.Now()
+			err := next(ctx, msg)
+
+			fields := []zap.Field{
+				zap.String("subject", msg.Subject),
+				zap.Duration("duration", time.Since(start)),
+			}
+			if meta, metaErr := msg.Metadata(); metaErr == nil {
+				fields = append(fields,
+					zap.Uint64("stream_seq", meta.Sequence.Stream),
+					zap.Uint64("consumer_seq", meta.Sequence.Consumer),
+					zap.Uint64("delivered", meta.NumDelivered))
+			}
+
+			if err != nil {
+				logger.Warn("message handling failed", append(fields, zap.Error(err))...)
+				return err
+			}
+			logger.Debug("message handled", fields...)
+			return nil
+		}
+	}
+}
+
+// RecoveryMiddleware 將處理過程中的 panic 轉換為錯誤，消息會延遲後重新投遞
+func RecoveryMiddleware(logger *zap.Logger) NatsMiddleware {
+	return func(next NatsHandler) NatsHandler {
+		return func(ctx context.Context, msg *nats.Msg) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error("message handler panic recovered",
+						zap.Any("panic", r),
+						zap.String("subject", msg.Subject),
+						zap.ByteString("stack", debug.Stack()))
+					err = fmt.Errorf("handler panic: %v", r)
+				}
+			}()
+			return next(ctx, msg)
+		}
+	}
+}
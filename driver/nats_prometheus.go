@@ -0,0 +1,138 @@
+package driver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NatsMetricsSource 提供指標快照，NatsManager 實現了該接口
+type NatsMetricsSource interface {
+	Metrics(ctx context.Context) (*NatsMetrics, error)
+}
+
+// natsCollector 在每次抓取時讀取 NatsMetrics 並轉換為 Prometheus 指標
+type natsCollector struct {
+	source NatsMetricsSource
+
+	// mu 避免並發抓取同時查詢服務器
+	mu sync.Mutex
+
+	up                 *prometheus.Desc
+	publishDuration    *prometheus.Desc
+	published          *prometheus.Desc
+	publishErrors      *prometheus.Desc
+	publishRetries     *prometheus.Desc
+	publishFailures    *prometheus.Desc
+	handlerDuration    *prometheus.Desc
+	handled            *prometheus.Desc
+	streamMessages     *prometheus.Desc
+	streamBytes        *prometheus.Desc
+	streamConsumers    *prometheus.Desc
+	consumerPending    *prometheus.Desc
+	consumerAckPending *prometheus.Desc
+	consumerRedeliver  *prometheus.Desc
+	consumerWaiting    *prometheus.Desc
+}
+
+// NewNatsCollector 創建導出 NatsManager 指標的 Prometheus collector，namespace 為空時使用 nexus
+// 使用方式：prometheus.MustRegister(driver.NewNatsCollector(nm, ""))
+func NewNatsCollector(source NatsMetricsSource, namespace string) prometheus.Collector {
+	if namespace == "" {
+		namespace = "nexus"
+	}
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "nats", name), help, labels, nil)
+	}
+
+	return &natsCollector{
+		source: source,
+
+		up: desc("up", "Whether stream and consumer state could be read from the server."),
+
+		publishDuration: desc("publish_duration_seconds", "Latency of synchronous publish attempts.", "subject"),
+		published:       desc("published_total", "Messages published successfully.", "subject"),
+		publishErrors:   desc("publish_errors_total", "Failed publish attempts, including async publishes.", "subject"),
+		publishRetries:  desc("publish_retries_total", "Publish retries.", "subject"),
+		publishFailures: desc("publish_failures_total", "Messages that could not be published after all retries.", "subject"),
+
+		handlerDuration: desc("handler_duration_seconds", "Time spent in message handlers.", "subject"),
+		handled:         desc("handled_total", "Handled messages by outcome.", "subject", "outcome"),
+
+		streamMessages:  desc("stream_messages", "Messages stored in the stream.", "stream"),
+		streamBytes:     desc("stream_bytes", "Bytes stored in the stream.", "stream"),
+		streamConsumers: desc("stream_consumers", "Consumers of the stream.", "stream"),
+
+		consumerPending:    desc("consumer_pending_messages", "Messages not yet delivered to the consumer.", "stream", "consumer"),
+		consumerAckPending: desc("consumer_ack_pending_messages", "Messages delivered but not yet acknowledged.", "stream", "consumer"),
+		consumerRedeliver:  desc("consumer_redelivered_messages", "Messages being redelivered.", "stream", "consumer"),
+		consumerWaiting:    desc("consumer_waiting_pulls", "Pending pull requests.", "stream", "consumer"),
+	}
+}
+
+func (c *natsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.up,
+		c.publishDuration, c.published, c.publishErrors, c.publishRetries, c.publishFailures,
+		c.handlerDuration, c.handled,
+		c.streamMessages, c.streamBytes, c.streamConsumers,
+		c.consumerPending, c.consumerAckPending, c.consumerRedeliver, c.consumerWaiting,
+	} {
+		ch <- d
+	}
+}
+
+func (c *natsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), managementTimeout)
+	defer cancel()
+
+	metrics, err := c.source.Metrics(ctx)
+	up := 1.0
+	if err != nil {
+		up = 0
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, up)
+	if metrics == nil {
+		return
+	}
+
+	for subject, p := range metrics.Publish {
+		ch <- constHistogram(c.publishDuration, p.Latency, subject)
+		ch <- prometheus.MustNewConstMetric(c.published, prometheus.CounterValue, float64(p.Published), subject)
+		ch <- prometheus.MustNewConstMetric(c.publishErrors, prometheus.CounterValue, float64(p.Errors), subject)
+		ch <- prometheus.MustNewConstMetric(c.publishRetries, prometheus.CounterValue, float64(p.Retries), subject)
+		ch <- prometheus.MustNewConstMetric(c.publishFailures, prometheus.CounterValue, float64(p.Failures), subject)
+	}
+
+	for subject, h := range metrics.Handlers {
+		ch <- constHistogram(c.handlerDuration, h.Duration, subject)
+		for outcome, count := range h.Outcomes {
+			ch <- prometheus.MustNewConstMetric(c.handled, prometheus.CounterValue, float64(count), subject, string(outcome))
+		}
+	}
+
+	for _, s := range metrics.Streams {
+		ch <- prometheus.MustNewConstMetric(c.streamMessages, prometheus.GaugeValue, float64(s.Messages), s.Name)
+		ch <- prometheus.MustNewConstMetric(c.streamBytes, prometheus.GaugeValue, float64(s.Bytes), s.Name)
+		ch <- prometheus.MustNewConstMetric(c.streamConsumers, prometheus.GaugeValue, float64(s.Consumers), s.Name)
+	}
+
+	for _, cm := range metrics.Consumers {
+		ch <- prometheus.MustNewConstMetric(c.consumerPending, prometheus.GaugeValue, float64(cm.Pending), cm.Stream, cm.Name)
+		ch <- prometheus.MustNewConstMetric(c.consumerAckPending, prometheus.GaugeValue, float64(cm.AckPending), cm.Stream, cm.Name)
+		ch <- prometheus.MustNewConstMetric(c.consumerRedeliver, prometheus.GaugeValue, float64(cm.Redelivered), cm.Stream, cm.Name)
+		ch <- prometheus.MustNewConstMetric(c.consumerWaiting, prometheus.GaugeValue, float64(cm.Waiting), cm.Stream, cm.Name)
+	}
+}
+
+func constHistogram(desc *prometheus.Desc, h Histogram, labels ...string) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(h.Buckets))
+	for i, upper := range h.Buckets {
+		buckets[upper] = h.Counts[i]
+	}
+	return prometheus.MustNewConstHistogram(desc, h.Count, h.Sum, buckets, labels...)
+}
@@ -0,0 +1,174 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"go.uber.org/zap"
+)
+
+// RetryPolicy 定義發布失敗時的重試策略
+type RetryPolicy struct {
+	MaxAttempts    int           `yaml:"max_attempts"`    // 最大嘗試次數，1 表示不重試
+	InitialBackoff time.Duration `yaml:"initial_backoff"` // 首次重試前的等待時間
+	MaxBackoff     time.Duration `yaml:"max_backoff"`     // 單次等待時間上限
+	Jitter         float64       `yaml:"jitter"`          // 隨機抖動比例 (0 ~ 1)
+
+	// Retryable 判斷錯誤是否值得重試，為 nil 時使用 DefaultRetryable
+	Retryable func(err error) bool `yaml:"-"`
+}
+
+// DefaultRetryPolicy 返回默認的發布重試策略
+// 3 max attempts
+// 100ms initial backoff
+// 2s max backoff
+// 0.2 jitter
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Jitter:         0.2,
+	}
+}
+
+// withDefaults 以默認值填充未設置的字段
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	def := DefaultRetryPolicy()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = def.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = def.MaxBackoff
+	}
+	if p.Retryable == nil {
+		p.Retryable = DefaultRetryable
+	}
+	return p
+}
+
+// Backoff 返回第 attempt 次失敗後 (從 0 開始) 應等待的時間
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	return RetryConfig{
+		InitialBackoff: p.InitialBackoff,
+		MaxBackoff:     p.MaxBackoff,
+		Jitter:         p.Jitter,
+	}.Backoff(attempt)
+}
+
+// DefaultRetryable 將配置或請求本身的錯誤視為不可重試，其他錯誤 (超時、無響應者等) 可重試
+func DefaultRetryable(err error) bool {
+	switch {
+	case errors.Is(err, ErrPermanent),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, jetstream.ErrJetStreamNotEnabled),
+		errors.Is(err, jetstream.ErrInvalidJSAck),
+		errors.Is(err, nats.ErrBadSubject),
+		errors.Is(err, nats.ErrMaxPayload),
+		errors.Is(err, nats.ErrConnectionClosed):
+		return false
+	}
+	return true
+}
+
+// PublishOption 覆蓋單次發布的重試策略
+type PublishOption func(*RetryPolicy)
+
+// WithRetryPolicy 使用指定的重試策略代替配置中的 PublishRetry
+func WithRetryPolicy(policy RetryPolicy) PublishOption {
+	return func(p *RetryPolicy) {
+		*p = policy
+	}
+}
+
+// WithMaxAttempts 設置最大嘗試次數
+func WithMaxAttempts(n int) PublishOption {
+	return func(p *RetryPolicy) {
+		p.MaxAttempts = n
+	}
+}
+
+// WithBackoff 設置重試的等待時間
+func WithBackoff(initial, max time.Duration) PublishOption {
+	return func(p *RetryPolicy) {
+		p.InitialBackoff = initial
+		p.MaxBackoff = max
+	}
+}
+
+// WithRetryable 設置判斷錯誤是否可重試的函數
+func WithRetryable(fn func(err error) bool) PublishOption {
+	return func(p *RetryPolicy) {
+		p.Retryable = fn
+	}
+}
+
+// WithoutRetry 只嘗試一次
+func WithoutRetry() PublishOption {
+	return WithMaxAttempts(1)
+}
+
+// publishPolicy 返回應用了 opts 的重試策略
+func (m *jetStreamNatsManager) publishPolicy(opts []PublishOption) RetryPolicy {
+	policy := m.config.PublishRetry
+	for _, opt := range opts {
+		opt(&policy)
+	}
+	return policy.withDefaults()
+}
+
+func (m *jetStreamNatsManager) publishMsg(ctx context.Context, msg *nats.Msg, opts ...PublishOption) error {
+	policy := m.publishPolicy(opts)
+	subject := msg.Subject
+	InjectTrace(ctx, msg)
+
+	var (
+		lastErr  error
+		attempts int
+	)
+	for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("context cancelled: %w", err)
+		}
+
+		attempts = attempt + 1
+		if lastErr = m.publishWithTimeout(ctx, msg); lastErr == nil {
+			return nil
+		}
+
+		if attempt == policy.MaxAttempts-1 || !policy.Retryable(lastErr) {
+			break
+		}
+
+		backoff := policy.Backoff(attempt)
+		m.logRetryAttempt(subject, attempt, policy.MaxAttempts, backoff, lastErr)
+		m.metrics.retry(subject)
+
+		select {
+		case <-ctx.Done():
+			m.metrics.failure(subject)
+			return fmt.Errorf("context cancelled during retry: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+	}
+
+	m.metrics.failure(subject)
+	return fmt.Errorf("failed to publish after %d attempts: %w", attempts, lastErr)
+}
+
+func (m *jetStreamNatsManager) logRetryAttempt(subject string, attempt, maxAttempts int, backoff time.Duration, err error) {
+	m.logger.Warn("failed to publish message, retrying",
+		zap.Error(err),
+		zap.String("subject", subject),
+		zap.Int("attempt", attempt+1),
+		zap.Int("max_attempts", maxAttempts),
+		zap.Duration("backoff", backoff))
+}
@@ -0,0 +1,209 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"go.uber.org/zap"
+)
+
+// RPC 使用的消息頭
+const (
+	ErrorHeader     = "Nexus-Error"      // 處理失敗時的錯誤信息
+	ErrorCodeHeader = "Nexus-Error-Code" // 處理失敗時的錯誤類型
+	DeadlineHeader  = "Nexus-Deadline"   // 調用方的截止時間 (RFC3339Nano)
+)
+
+// 錯誤類型
+const (
+	ErrorCodeInternal  = "internal"
+	ErrorCodePermanent = "permanent"
+	ErrorCodeDecode    = "decode"
+)
+
+// defaultRequestTimeout 是 ctx 沒有截止時間時的請求超時時間
+const defaultRequestTimeout = 10 * time.Second
+
+// RemoteError 是遠端處理請求時返回的錯誤
+type RemoteError struct {
+	Subject string
+	Code    string
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("remote error from %s (%s): %s", e.Subject, e.Code, e.Message)
+}
+
+// Is 讓 permanent 類型的遠端錯誤與 ErrPermanent 匹配
+func (e *RemoteError) Is(target error) bool {
+	return target == ErrPermanent && e.Code == ErrorCodePermanent
+}
+
+// RequestHandler 處理一個請求並返回響應，decode 按請求的 Content-Type 解碼請求體
+type RequestHandler func(ctx context.Context, msg *nats.Msg, decode func(v any) error) (any, error)
+
+// Request 編碼 req 發送請求，並將響應解碼到 resp；resp 為 nil 時忽略響應體
+// 截止時間取自 ctx，未設置時使用默認超時
+func (m *jetStreamNatsManager) Request(ctx context.Context, subject string, req, resp any) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultRequestTimeout)
+		defer cancel()
+	}
+
+	codec := m.codec()
+	data, err := codec.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	msg := &nats.Msg{Subject: subject, Data: data, Header: nats.Header{}}
+	msg.Header.Set(ContentTypeHeader, codec.ContentType())
+	if deadline, ok := ctx.Deadline(); ok {
+		msg.Header.Set(DeadlineHeader, deadline.UTC().Format(time.RFC3339Nano))
+	}
+
+	InjectTrace(ctx, msg)
+	reply, err := m.nc.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		if errors.Is(err, nats.ErrNoResponders) {
+			return fmt.Errorf("no responders for %s: %w", subject, err)
+		}
+		return fmt.Errorf("request to %s failed: %w", subject, err)
+	}
+
+	if message := reply.Header.Get(ErrorHeader); message != "" {
+		return &RemoteError{
+			Subject: subject,
+			Code:    reply.Header.Get(ErrorCodeHeader),
+			Message: message,
+		}
+	}
+
+	if resp == nil {
+		return nil
+	}
+	if err = CodecFor(reply.Header.Get(ContentTypeHeader)).Unmarshal(reply.Data, resp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// HandleRequest 在 subject 上處理請求，同一主題的多個副本組成隊列組分擔請求
+// 請求在 worker pool 中處理，並發數受 worker pool 容量限制
+func (m *jetStreamNatsManager) HandleRequest(subject string, handler RequestHandler) (*nats.Subscription, error) {
+	sub, err := m.nc.QueueSubscribe(subject, subject, func(msg *nats.Msg) {
+		if msg.Reply == "" {
+			m.logger.Warn("dropping request without reply subject",
+				zap.String("subject", subject))
+			return
+		}
+
+		if err := m.pool.Submit(m.ctx, func() error {
+			return m.serveRequest(msg, handler)
+		}); err != nil {
+			m.logger.Error("failed to submit request to worker pool",
+				zap.Error(err),
+				zap.String("subject", subject))
+			m.respondError(msg, ErrorCodeInternal, err)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to requests: %w", err)
+	}
+	return sub, nil
+}
+
+func (m *jetStreamNatsManager) serveRequest(msg *nats.Msg, handler RequestHandler) error {
+	ctx := ExtractTrace(m.ctx, msg.Header)
+	if deadline, err := time.Parse(time.RFC3339Nano, msg.Header.Get(DeadlineHeader)); err == nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	requestCodec := CodecFor(msg.Header.Get(ContentTypeHeader))
+	decode := func(v any) error {
+		if err := requestCodec.Unmarshal(msg.Data, v); err != nil {
+			return &decodeError{err: err}
+		}
+		return nil
+	}
+
+	resp, err := handler(ctx, msg, decode)
+	if err != nil {
+		var de *decodeError
+		switch {
+		case errors.As(err, &de):
+			m.respondError(msg, ErrorCodeDecode, err)
+		case errors.Is(err, ErrPermanent):
+			m.respondError(msg, ErrorCodePermanent, err)
+		default:
+			m.respondError(msg, ErrorCodeInternal, err)
+		}
+		return err
+	}
+
+	// 響應使用與請求相同的編碼格式
+	data, err := requestCodec.Marshal(resp)
+	if err != nil {
+		m.respondError(msg, ErrorCodeInternal, fmt.Errorf("failed to encode response: %w", err))
+		return err
+	}
+
+	reply := &nats.Msg{Subject: msg.Reply, Data: data, Header: nats.Header{}}
+	reply.Header.Set(ContentTypeHeader, requestCodec.ContentType())
+	if err = msg.RespondMsg(reply); err != nil {
+		m.logger.Error("failed to send response",
+			zap.Error(err),
+			zap.String("subject", msg.Subject))
+		return err
+	}
+	return nil
+}
+
+func (m *jetStreamNatsManager) respondError(msg *nats.Msg, code string, err error) {
+	reply := &nats.Msg{Subject: msg.Reply, Header: nats.Header{}}
+	reply.Header.Set(ErrorHeader, err.Error())
+	reply.Header.Set(ErrorCodeHeader, code)
+	if respErr := msg.RespondMsg(reply); respErr != nil {
+		m.logger.Error("failed to send error response",
+			zap.Error(respErr),
+			zap.String("subject", msg.Subject))
+	}
+}
+
+func (m *jetStreamNatsManager) codec() Codec {
+	return CodecFor(m.config.Codec)
+}
+
+// decodeError 標記請求體解碼失敗
+type decodeError struct {
+	err error
+}
+
+func (e *decodeError) Error() string { return "failed to decode request: " + e.err.Error() }
+func (e *decodeError) Unwrap() error { return e.err }
+
+// HandleTypedRequest 使用強類型的處理函數處理請求
+func HandleTypedRequest[Req, Resp any](nm NatsManager, subject string, handler func(ctx context.Context, req Req) (Resp, error)) (*nats.Subscription, error) {
+	return nm.HandleRequest(subject, func(ctx context.Context, _ *nats.Msg, decode func(v any) error) (any, error) {
+		var req Req
+		if err := decode(&req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	})
+}
+
+// RequestTyped 發送請求並返回強類型的響應
+func RequestTyped[Resp any](ctx context.Context, nm NatsManager, subject string, req any) (Resp, error) {
+	var resp Resp
+	err := nm.Request(ctx, subject, req, &resp)
+	return resp, err
+}
@@ -0,0 +1,204 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"go.uber.org/zap"
+)
+
+// 延遲消息攜帶的消息頭
+const (
+	ScheduleTargetHeader = "Nexus-Schedule-Target" // 到期後發布的主題
+	ScheduleAtHeader     = "Nexus-Schedule-At"     // 到期時間 (RFC3339Nano)
+)
+
+const (
+	// defaultScheduleMaxDelay 是未到期消息單次等待的上限，超過後重新檢查
+	defaultScheduleMaxDelay = time.Hour
+
+	// scheduleAckWait 是調度器處理一條到期消息的時間
+	scheduleAckWait = 30 * time.Second
+)
+
+// ErrScheduleOverlap 表示延遲消息的主題被其他 stream 的主題覆蓋，延遲消息會被立即投遞給訂閱者
+var ErrScheduleOverlap = errors.New("schedule subject overlaps stream subjects")
+
+// ScheduleConfig 定義延遲消息配置
+// 延遲消息存放在 <StreamName>_SCHEDULE stream 的 <StreamName>.SCHEDULE.> 主題下，
+// 所有實例共享同一個持久消費者，到期後由其中一個實例發布到目標主題；其他 stream 的主題不能與之重疊
+type ScheduleConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Storage  string        `yaml:"storage"`   // file 或 memory，默認 file
+	Replicas int           `yaml:"replicas"`  // 集群中的副本數，默認 1
+	MaxDelay time.Duration `yaml:"max_delay"` // 未到期消息單次等待的上限，默認 1 小時
+}
+
+func (m *jetStreamNatsManager) scheduleStreamName() string {
+	return m.config.StreamName + "_SCHEDULE"
+}
+
+// scheduleSubject 返回延遲發布到 subject 的消息在 schedule stream 中的主題
+func (m *jetStreamNatsManager) scheduleSubject(subject string) string {
+	return fmt.Sprintf("%s.SCHEDULE.%s", m.config.StreamName, subject)
+}
+
+// PublishAt 在 when 時將消息發布到 subject，when 已過去時立即發布
+// 延遲消息持久化在 JetStream 中，重啟後仍會投遞；投遞語義為至少一次
+func (m *jetStreamNatsManager) PublishAt(ctx context.Context, subject string, data []byte, when time.Time, opts ...PublishOption) error {
+	if !when.After(time.Now()) {
+		return m.Publish(ctx, subject, data, opts...)
+	}
+	if !m.config.Schedule.Enabled {
+		return fmt.Errorf("scheduled delivery is not enabled")
+	}
+
+	msg := &nats.Msg{
+		Subject: m.scheduleSubject(subject),
+		Data:    data,
+		Header:  nats.Header{},
+	}
+	msg.Header.Set(ScheduleTargetHeader, subject)
+	msg.Header.Set(ScheduleAtHeader, when.UTC().Format(time.RFC3339Nano))
+
+	return m.publishMsg(ctx, msg, opts...)
+}
+
+// PublishAfter 在 delay 之後將消息發布到 subject
+func (m *jetStreamNatsManager) PublishAfter(ctx context.Context, subject string, data []byte, delay time.Duration, opts ...PublishOption) error {
+	return m.PublishAt(ctx, subject, data, time.Now().Add(delay), opts...)
+}
+
+// setupScheduler 創建 schedule stream 和共享的持久消費者，並開始調度
+func (m *jetStreamNatsManager) setupScheduler(ctx context.Context) error {
+	if err := m.config.checkReservedSubject(m.scheduleSubject(">"), ErrScheduleOverlap); err != nil {
+		return err
+	}
+
+	storage := m.config.Schedule.Storage
+	if storage == "" {
+		storage = "file"
+	}
+	config, err := StreamConfig{
+		Name:      m.scheduleStreamName(),
+		Subjects:  []string{m.scheduleSubject(">")},
+		Storage:   storage,
+		Retention: "workqueue",
+		Replicas:  m.config.Schedule.Replicas,
+	}.toJetStream()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	err = m.createOrUpdateStream(ctx, config)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err = m.requireStream(ctx, config.Name, ErrScheduleOverlap); err != nil {
+		return err
+	}
+
+	consumer, err := m.jetStream.CreateOrUpdateConsumer(ctx, config.Name, jetstream.ConsumerConfig{
+		Durable: m.getDurableName("SCHEDULE"),
+		AckWait: scheduleAckWait,
+		// 未到期的消息會被多次延遲重投，不能限制投遞次數
+		MaxDeliver: -1,
+		// 未到期的消息在延遲期間一直處於待確認狀態，限制待確認數量會讓遠期消息阻塞先到期的消息
+		MaxAckPending: -1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create scheduler consumer: %w", err)
+	}
+
+	cc, err := consumer.Consume(m.dispatchScheduled,
+		jetstream.PullMaxMessages(max(m.pool.Cap(), 1)),
+		jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+			m.logger.Warn("scheduler consumer error", zap.Error(err))
+		}))
+	if err != nil {
+		return fmt.Errorf("failed to start scheduler: %w", err)
+	}
+
+	go func() {
+		<-m.ctx.Done()
+		cc.Stop()
+	}()
+	return nil
+}
+
+// dispatchScheduled 發布到期的消息，未到期的消息延遲到到期時間 (不超過 MaxDelay) 後重新檢查
+func (m *jetStreamNatsManager) dispatchScheduled(msg jetstream.Msg) {
+	logger := m.logger.With(zap.String("subject", msg.Subject()))
+
+	target := msg.Headers().Get(ScheduleTargetHeader)
+	at, err := time.Parse(time.RFC3339Nano, msg.Headers().Get(ScheduleAtHeader))
+	if target == "" || err != nil {
+		logger.Error("dropping invalid scheduled message",
+			zap.Error(err),
+			zap.String("target", target))
+		if termErr := msg.Term(); termErr != nil {
+			logger.Error("failed to terminate message", zap.Error(termErr))
+		}
+		return
+	}
+
+	if wait := time.Until(at); wait > 0 {
+		maxDelay := m.config.Schedule.MaxDelay
+		if maxDelay <= 0 {
+			maxDelay = defaultScheduleMaxDelay
+		}
+		if nakErr := msg.NakWithDelay(min(wait, maxDelay)); nakErr != nil {
+			logger.Error("failed to nak message", zap.Error(nakErr))
+		}
+		return
+	}
+
+	if err = m.pool.Submit(m.ctx, func() error {
+		return m.deliverScheduled(msg, target)
+	}); err != nil {
+		logger.Error("failed to submit message to worker pool", zap.Error(err))
+		m.nakMessage(msg.Subject(), msg)
+	}
+}
+
+// deliverScheduled 將到期的消息發布到目標主題後確認
+// 發布使用基於原始序列號的 Nats-Msg-Id，確認失敗導致的重複發布會在去重窗口內被丟棄
+func (m *jetStreamNatsManager) deliverScheduled(msg jetstream.Msg, target string) error {
+	headers := nats.Header{}
+	for k, v := range msg.Headers() {
+		headers[k] = append([]string(nil), v...)
+	}
+	headers.Del(ScheduleTargetHeader)
+	headers.Del(ScheduleAtHeader)
+	if meta, err := msg.Metadata(); err == nil && headers.Get(nats.MsgIdHdr) == "" {
+		headers.Set(nats.MsgIdHdr, m.scheduleStreamName()+"-"+strconv.FormatUint(meta.Sequence.Stream, 10))
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, scheduleAckWait)
+	defer cancel()
+
+	if err := m.publishMsg(ctx, &nats.Msg{Subject: target, Data: msg.Data(), Header: headers}); err != nil {
+		m.logger.Error("failed to deliver scheduled message",
+			zap.Error(err),
+			zap.String("target", target))
+		if !errors.Is(err, context.Canceled) {
+			m.nakMessage(msg.Subject(), msg)
+		}
+		return err
+	}
+
+	if err := msg.Ack(); err != nil {
+		m.logger.Error("failed to ack scheduled message",
+			zap.Error(err),
+			zap.String("target", target))
+	}
+	return nil
+}
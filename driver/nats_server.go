@@ -0,0 +1,63 @@
+package driver
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// EmbeddedBuildTag 是啟用內嵌 NATS 服務器所需的構建標籤
+// nats-server 依賴較重，默認構建不包含，需要使用 go build -tags nats_embedded
+const EmbeddedBuildTag = "nats_embedded"
+
+// ErrEmbeddedUnavailable 表示當前構建未包含內嵌 NATS 服務器
+var ErrEmbeddedUnavailable = errors.New("embedded nats server is not available: build with -tags " + EmbeddedBuildTag)
+
+// defaultEmbeddedReadyTimeout 是等待內嵌服務器就緒的默認時間
+const defaultEmbeddedReadyTimeout = 10 * time.Second
+
+// EmbeddedNatsConfig 定義內嵌 NATS 服務器的配置
+type EmbeddedNatsConfig struct {
+	Host         string        `yaml:"host"`          // 監聽地址，默認 127.0.0.1
+	Port         int           `yaml:"port"`          // 監聽端口，0 表示隨機端口
+	StoreDir     string        `yaml:"store_dir"`     // JetStream 存儲目錄，為空時使用臨時目錄並在關閉時刪除
+	MaxMemory    int64         `yaml:"max_memory"`    // JetStream 內存存儲上限，0 表示由服務器決定
+	MaxStore     int64         `yaml:"max_store"`     // JetStream 文件存儲上限，0 表示由服務器決定
+	ReadyTimeout time.Duration `yaml:"ready_timeout"` // 等待服務器就緒的時間
+	Debug        bool          `yaml:"debug"`         // 輸出服務器的 debug 日誌
+}
+
+// EmbeddedServer 是在進程內運行的 NATS 服務器
+type EmbeddedServer struct {
+	url      string
+	shutdown func()
+}
+
+// ClientURL 返回客戶端連接地址
+func (s *EmbeddedServer) ClientURL() string {
+	return s.url
+}
+
+// Shutdown 關閉服務器並清理臨時存儲，可重複調用
+func (s *EmbeddedServer) Shutdown() {
+	if s.shutdown != nil {
+		s.shutdown()
+		s.shutdown = nil
+	}
+}
+
+// StartEmbeddedNats 啟動開啟 JetStream 的內嵌 NATS 服務器，並等待其可以接受連接
+// 未使用 nats_embedded 標籤構建時返回 ErrEmbeddedUnavailable
+func StartEmbeddedNats(config EmbeddedNatsConfig, logger *zap.Logger) (*EmbeddedServer, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if config.Host == "" {
+		config.Host = "127.0.0.1"
+	}
+	if config.ReadyTimeout <= 0 {
+		config.ReadyTimeout = defaultEmbeddedReadyTimeout
+	}
+	return startEmbeddedNats(config, logger)
+}
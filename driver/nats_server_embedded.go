@@ -0,0 +1,92 @@
+//go:build nats_embedded
+
+package driver
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nats-io/nats-server/v2/server"
+
+	"go.uber.org/zap"
+)
+
+func startEmbeddedNats(config EmbeddedNatsConfig, logger *zap.Logger) (*EmbeddedServer, error) {
+	storeDir := config.StoreDir
+	tempDir := storeDir == ""
+	if tempDir {
+		dir, err := os.MkdirTemp("", "nexus-nats-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create jetstream store dir: %w", err)
+		}
+		storeDir = dir
+	}
+
+	cleanup := func() {
+		if tempDir {
+			if err := os.RemoveAll(storeDir); err != nil {
+				logger.Warn("failed to remove jetstream store dir",
+					zap.Error(err),
+					zap.String("dir", storeDir))
+			}
+		}
+	}
+
+	port := config.Port
+	if port == 0 {
+		port = server.RANDOM_PORT
+	}
+
+	opts := &server.Options{
+		ServerName:         "nexus-embedded",
+		Host:               config.Host,
+		Port:               port,
+		JetStream:          true,
+		StoreDir:           storeDir,
+		JetStreamMaxMemory: config.MaxMemory,
+		JetStreamMaxStore:  config.MaxStore,
+		NoSigs:             true,
+	}
+
+	ns, err := server.NewServer(opts)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to create embedded nats server: %w", err)
+	}
+	ns.SetLoggerV2(&serverLogger{logger: logger.Named("nats-server")}, config.Debug, false, false)
+
+	go ns.Start()
+
+	if !ns.ReadyForConnections(config.ReadyTimeout) {
+		ns.Shutdown()
+		cleanup()
+		return nil, fmt.Errorf("embedded nats server not ready after %s", config.ReadyTimeout)
+	}
+
+	logger.Info("embedded nats server started",
+		zap.String("url", ns.ClientURL()),
+		zap.String("store_dir", storeDir))
+
+	return &EmbeddedServer{
+		url: ns.ClientURL(),
+		shutdown: func() {
+			ns.Shutdown()
+			ns.WaitForShutdown()
+			cleanup()
+		},
+	}, nil
+}
+
+// serverLogger 將 nats-server 的日誌輸出到 zap
+type serverLogger struct {
+	logger *zap.Logger
+}
+
+func (l *serverLogger) Noticef(format string, v ...any) { l.logger.Info(fmt.Sprintf(format, v...)) }
+func (l *serverLogger) Warnf(format string, v ...any)   { l.logger.Warn(fmt.Sprintf(format, v...)) }
+func (l *serverLogger) Errorf(format string, v ...any)  { l.logger.Error(fmt.Sprintf(format, v...)) }
+func (l *serverLogger) Debugf(format string, v ...any)  { l.logger.Debug(fmt.Sprintf(format, v...)) }
+func (l *serverLogger) Tracef(format string, v ...any)  { l.logger.Debug(fmt.Sprintf(format, v...)) }
+
+// Fatalf 不退出進程，由 ReadyForConnections 的結果報告啟動失敗
+func (l *serverLogger) Fatalf(format string, v ...any) { l.logger.Error(fmt.Sprintf(format, v...)) }
@@ -0,0 +1,9 @@
+//go:build !nats_embedded
+
+package driver
+
+import "go.uber.org/zap"
+
+func startEmbeddedNats(EmbeddedNatsConfig, *zap.Logger) (*EmbeddedServer, error) {
+	return nil, ErrEmbeddedUnavailable
+}
@@ -0,0 +1,198 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"go.uber.org/zap"
+)
+
+// StreamConfig 定義一個 JetStream stream 的配置
+type StreamConfig struct {
+	Name            string        `yaml:"name"`
+	Subjects        []string      `yaml:"subjects"`         // 為空時 stream 只接收與名稱相同的主題
+	Storage         string        `yaml:"storage"`          // file 或 memory，默認 file
+	Retention       string        `yaml:"retention"`        // limits、interest 或 workqueue，默認 workqueue
+	Replicas        int           `yaml:"replicas"`         // 集群中的副本數，默認 1
+	Discard         string        `yaml:"discard"`          // 達到上限時丟棄 old 或 new 消息，默認 old
+	DuplicateWindow time.Duration `yaml:"duplicate_window"` // 按 Nats-Msg-Id 去重的時間窗口，默認由服務器決定
+	MaxAge          time.Duration `yaml:"max_age"`
+	MaxMsgs         int64         `yaml:"max_msgs"`
+	MaxBytes        int64         `yaml:"max_bytes"`
+	MaxMsgSize      int32         `yaml:"max_msg_size"` // 單條消息的最大字節數，0 表示不限制
+}
+
+// streamConfigs 返回需要聲明的所有 stream
+// StreamName 及其同級字段描述主 stream，Streams 中同名的配置優先
+func (c NatsConfig) streamConfigs() []StreamConfig {
+	configs := make([]StreamConfig, 0, len(c.Streams)+1)
+
+	if c.StreamName != "" {
+		primary := StreamConfig{
+			Name:            c.StreamName,
+			Subjects:        c.Subjects,
+			Storage:         c.Storage,
+			Retention:       c.Retention,
+			Replicas:        c.Replicas,
+			Discard:         c.Discard,
+			DuplicateWindow: c.DuplicateWindow,
+			MaxAge:          c.MaxAge,
+			MaxMsgs:         c.MaxMsgs,
+			MaxBytes:        c.MaxBytes,
+			MaxMsgSize:      c.MaxMsgSize,
+		}
+		declared := false
+		for _, s := range c.Streams {
+			if s.Name == c.StreamName {
+				declared = true
+				break
+			}
+		}
+		if !declared {
+			configs = append(configs, primary)
+		}
+	}
+
+	return append(configs, c.Streams...)
+}
+
+// toJetStream 將配置轉換為 jetstream.StreamConfig
+func (c StreamConfig) toJetStream() (jetstream.StreamConfig, error) {
+	if c.Name == "" {
+		return jetstream.StreamConfig{}, errors.New("stream name is required")
+	}
+
+	storage, err := parseStorage(c.Storage)
+	if err != nil {
+		return jetstream.StreamConfig{}, err
+	}
+	retention, err := parseRetention(c.Retention)
+	if err != nil {
+		return jetstream.StreamConfig{}, err
+	}
+	discard, err := parseDiscard(c.Discard)
+	if err != nil {
+		return jetstream.StreamConfig{}, err
+	}
+
+	config := jetstream.StreamConfig{
+		Name:       c.Name,
+		Subjects:   c.Subjects,
+		Storage:    storage,
+		Retention:  retention,
+		Discard:    discard,
+		Replicas:   max(c.Replicas, 1),
+		Duplicates: c.DuplicateWindow,
+		MaxAge:     c.MaxAge,
+		MaxMsgs:    c.MaxMsgs,
+		MaxBytes:   c.MaxBytes,
+		MaxMsgSize: c.MaxMsgSize,
+	}
+	if config.MaxMsgs == 0 {
+		config.MaxMsgs = -1
+	}
+	if config.MaxBytes == 0 {
+		config.MaxBytes = -1
+	}
+	if config.MaxMsgSize == 0 {
+		config.MaxMsgSize = -1
+	}
+	return config, nil
+}
+
+func parseStorage(s string) (jetstream.StorageType, error) {
+	switch strings.ToLower(s) {
+	case "", "file":
+		return jetstream.FileStorage, nil
+	case "memory":
+		return jetstream.MemoryStorage, nil
+	default:
+		return 0, fmt.Errorf("invalid stream storage %q", s)
+	}
+}
+
+func parseRetention(s string) (jetstream.RetentionPolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "workqueue":
+		return jetstream.WorkQueuePolicy, nil
+	case "limits":
+		return jetstream.LimitsPolicy, nil
+	case "interest":
+		return jetstream.InterestPolicy, nil
+	default:
+		return 0, fmt.Errorf("invalid stream retention %q", s)
+	}
+}
+
+func parseDiscard(s string) (jetstream.DiscardPolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "old":
+		return jetstream.DiscardOld, nil
+	case "new":
+		return jetstream.DiscardNew, nil
+	default:
+		return 0, fmt.Errorf("invalid stream discard policy %q", s)
+	}
+}
+
+// checkReservedSubject 檢查 reserved 主題沒有被配置中的 stream 覆蓋，覆蓋時返回包裝 sentinel 的錯誤
+// 未設置主題的 stream 使用 stream 名稱作為主題
+func (c NatsConfig) checkReservedSubject(reserved string, sentinel error) error {
+	for _, sc := range c.streamConfigs() {
+		subjects := sc.Subjects
+		if len(subjects) == 0 {
+			subjects = []string{sc.Name}
+		}
+		for _, subject := range subjects {
+			if subjectsOverlap(subject, reserved) {
+				return fmt.Errorf("%w: stream %s subject %q covers %q", sentinel, sc.Name, subject, reserved)
+			}
+		}
+	}
+	return nil
+}
+
+// requireStream 確認 stream 已經存在
+// createOrUpdateStream 會忽略主題重疊的錯誤，服務器上其他 stream 覆蓋了主題時 stream 不會被創建，此時返回包裝 sentinel 的錯誤
+func (m *jetStreamNatsManager) requireStream(ctx context.Context, name string, sentinel error) error {
+	if _, err := m.jetStream.Stream(ctx, name); err != nil {
+		if errors.Is(err, jetstream.ErrStreamNotFound) {
+			return fmt.Errorf("%w: stream %s was not created", sentinel, name)
+		}
+		return fmt.Errorf("failed to get stream %s: %w", name, err)
+	}
+	return nil
+}
+
+// setupStreams 創建或更新配置中聲明的所有 stream
+func (m *jetStreamNatsManager) setupStreams(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for _, sc := range m.config.streamConfigs() {
+		config, err := sc.toJetStream()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stream %q: %w", sc.Name, err))
+			continue
+		}
+
+		if err = m.createOrUpdateStream(ctx, config); err != nil {
+			// JetStream 未開啟時無需繼續
+			if errors.Is(err, jetstream.ErrJetStreamNotEnabled) {
+				return err
+			}
+			m.logger.Warn("failed to set up stream",
+				zap.Error(err),
+				zap.String("name", sc.Name))
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,31 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestParseStorage(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    jetstream.StorageType
+		wantErr bool
+	}{
+		{"", jetstream.FileStorage, false},
+		{"file", jetstream.FileStorage, false},
+		{"FILE", jetstream.FileStorage, false},
+		{"memory", jetstream.MemoryStorage, false},
+		{"disk", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseStorage(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseStorage(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseStorage(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package driver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// tracePropagator 按 W3C Trace Context 規範傳遞 traceparent 和 tracestate，並傳遞 baggage
+var tracePropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// headerCarrier 將 nats.Header 適配為 propagation.TextMapCarrier
+// 讀取時忽略大小寫，兼容其他客戶端寫入的 traceparent 或 Traceparent
+type headerCarrier nats.Header
+
+func (c headerCarrier) Get(key string) string {
+	if v := nats.Header(c).Get(key); v != "" {
+		return v
+	}
+	for k, v := range c {
+		if strings.EqualFold(k, key) && len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	nats.Header(c).Set(key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// InjectTrace 將 ctx 中的追蹤上下文寫入消息頭，ctx 中沒有追蹤信息時不修改消息
+// 消息頭會被複製，不影響調用方傳入的 nats.Header
+func InjectTrace(ctx context.Context, msg *nats.Msg) {
+	carrier := headerCarrier{}
+	tracePropagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return
+	}
+
+	headers := make(nats.Header, len(msg.Header)+len(carrier))
+	for k, v := range msg.Header {
+		headers[k] = v
+	}
+	for k, v := range carrier {
+		headers[k] = v
+	}
+	msg.Header = headers
+}
+
+// ExtractTrace 從消息頭中讀取追蹤上下文並附加到 ctx
+func ExtractTrace(ctx context.Context, headers nats.Header) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	return tracePropagator.Extract(ctx, headerCarrier(headers))
+}
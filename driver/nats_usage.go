@@ -0,0 +1,317 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"go.uber.org/zap"
+)
+
+// ErrStreamDegraded 表示 stream 的用量達到了告警閾值，HealthCheck 返回的錯誤可用 errors.Is 判斷
+var ErrStreamDegraded = errors.New("stream usage degraded")
+
+// UsageResource 是 stream 受限制的資源
+type UsageResource string
+
+const (
+	UsageMessages UsageResource = "messages" // 消息數，對應 MaxMsgs
+	UsageBytes    UsageResource = "bytes"    // 字節數，對應 MaxBytes
+	UsageAge      UsageResource = "age"      // 最早消息的存在時間 (秒)，對應 MaxAge
+)
+
+// UsageLevel 是用量的告警級別
+type UsageLevel string
+
+const (
+	UsageOK       UsageLevel = "ok"
+	UsageWarning  UsageLevel = "warning"
+	UsageCritical UsageLevel = "critical"
+)
+
+// UsageConfig 定義 stream 用量告警
+// 閾值是用量與限制的比例，未設置限制的資源不檢查
+type UsageConfig struct {
+	WarningThreshold  float64       `yaml:"warning_threshold"`  // 默認 0.9
+	CriticalThreshold float64       `yaml:"critical_threshold"` // 默認 0.98
+	Interval          time.Duration `yaml:"interval"`           // 後台檢查間隔，0 表示只在 HealthCheck 時檢查
+	AlertSubject      string        `yaml:"alert_subject"`      // 級別變化時以 JSON 發布 UsageAlert 的主題，為空表示不發布
+}
+
+func (c UsageConfig) withDefaults() UsageConfig {
+	if c.WarningThreshold <= 0 {
+		c.WarningThreshold = 0.9
+	}
+	if c.CriticalThreshold <= 0 {
+		c.CriticalThreshold = 0.98
+	}
+	return c
+}
+
+// validate 檢查 withDefaults 之後的閾值，要求 0 < WarningThreshold < CriticalThreshold <= 1
+func (c UsageConfig) validate() error {
+	if c.WarningThreshold >= c.CriticalThreshold || c.CriticalThreshold > 1 {
+		return fmt.Errorf("invalid usage thresholds: warning %g must be below critical %g, and critical must not exceed 1",
+			c.WarningThreshold, c.CriticalThreshold)
+	}
+	return nil
+}
+
+func (c UsageConfig) level(ratio float64) UsageLevel {
+	switch {
+	case ratio >= c.CriticalThreshold:
+		return UsageCritical
+	case ratio >= c.WarningThreshold:
+		return UsageWarning
+	default:
+		return UsageOK
+	}
+}
+
+// StreamUsage 是 stream 某項資源的用量
+type StreamUsage struct {
+	Stream   string        `json:"stream"`
+	Resource UsageResource `json:"resource"`
+	Current  float64       `json:"current"`
+	Limit    float64       `json:"limit"`
+	Ratio    float64       `json:"ratio"`
+	Level    UsageLevel    `json:"level"`
+}
+
+func (u StreamUsage) String() string {
+	return fmt.Sprintf("%s %s at %.0f%% (%.0f/%.0f)", u.Stream, u.Resource, u.Ratio*100, u.Current, u.Limit)
+}
+
+// UsageAlert 在某項資源的告警級別變化時產生，包括恢復到 ok
+type UsageAlert struct {
+	StreamUsage
+	Previous UsageLevel `json:"previous"`
+	Time     time.Time  `json:"time"`
+}
+
+// UsageHook 處理用量告警，在檢查用量的 goroutine 中同步調用
+type UsageHook func(ctx context.Context, alert UsageAlert)
+
+// StreamError 表示無法讀取某個 stream 的狀態
+type StreamError struct {
+	Stream string
+	Err    error
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("failed to get stream %s info: %v", e.Stream, e.Err)
+}
+
+func (e *StreamError) Unwrap() error { return e.Err }
+
+// StreamDegradedError 列出達到告警閾值的用量和無法讀取狀態的次要 stream
+type StreamDegradedError struct {
+	Usage       []StreamUsage
+	Unavailable []*StreamError
+}
+
+func (e *StreamDegradedError) Error() string {
+	parts := make([]string, 0, len(e.Usage)+len(e.Unavailable))
+	for _, u := range e.Usage {
+		parts = append(parts, u.String())
+	}
+	for _, f := range e.Unavailable {
+		parts = append(parts, f.Error())
+	}
+	return fmt.Sprintf("%s: %s", ErrStreamDegraded, strings.Join(parts, ", "))
+}
+
+func (e *StreamDegradedError) Unwrap() error { return ErrStreamDegraded }
+
+// usageTracker 記錄每項資源上次的告警級別，只在級別變化時告警
+type usageTracker struct {
+	mu     sync.Mutex
+	levels map[string]UsageLevel
+	hooks  []UsageHook
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{levels: make(map[string]UsageLevel)}
+}
+
+// transition 更新級別並返回之前的級別，級別未變化時 changed 為 false
+func (t *usageTracker) transition(u StreamUsage) (previous UsageLevel, changed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := u.Stream + "/" + string(u.Resource)
+	previous, ok := t.levels[key]
+	if !ok {
+		previous = UsageOK
+	}
+	t.levels[key] = u.Level
+	return previous, previous != u.Level
+}
+
+func (t *usageTracker) snapshotHooks() []UsageHook {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]UsageHook(nil), t.hooks...)
+}
+
+// OnUsageAlert 註冊用量告警的回調
+func (m *jetStreamNatsManager) OnUsageAlert(hooks ...UsageHook) {
+	m.usage.mu.Lock()
+	defer m.usage.mu.Unlock()
+	m.usage.hooks = append(m.usage.hooks, hooks...)
+}
+
+// Usage 返回配置中所有 stream 的用量
+// 讀取某個 stream 失敗時仍返回其他 stream 的用量，錯誤是每個失敗 stream 的 *StreamError 的組合
+func (m *jetStreamNatsManager) Usage(ctx context.Context) ([]StreamUsage, error) {
+	usage, failed := m.streamsUsage(ctx)
+	errs := make([]error, len(failed))
+	for i, f := range failed {
+		errs[i] = f
+	}
+	return usage, errors.Join(errs...)
+}
+
+func (m *jetStreamNatsManager) streamsUsage(ctx context.Context) ([]StreamUsage, []*StreamError) {
+	config := m.config.Usage.withDefaults()
+
+	var (
+		usage  []StreamUsage
+		failed []*StreamError
+	)
+	for _, sc := range m.config.streamConfigs() {
+		stream, err := m.jetStream.Stream(ctx, sc.Name)
+		if err != nil {
+			failed = append(failed, &StreamError{Stream: sc.Name, Err: err})
+			continue
+		}
+		info, err := stream.Info(ctx)
+		if err != nil {
+			failed = append(failed, &StreamError{Stream: sc.Name, Err: err})
+			continue
+		}
+		usage = append(usage, streamUsage(info, config)...)
+	}
+	return usage, failed
+}
+
+// streamUsage 按服務器上的限制計算用量，限制為 0 或 -1 (不限制) 的資源被跳過
+// 只檢查達到上限會造成問題的資源：
+//   - 消息數和字節數：丟棄新消息時發布會被拒絕，workqueue 和 interest 會丟棄未處理的消息；
+//     limits 保留策略且丟棄舊消息的 stream 在上限處滾動是正常狀態，不檢查
+//   - 存在時間：workqueue 和 interest 的消息過期即未被處理；limits 保留策略的消息按 MaxAge 過期是正常狀態，不檢查
+func streamUsage(info *jetstream.StreamInfo, config UsageConfig) []StreamUsage {
+	var usage []StreamUsage
+	add := func(resource UsageResource, current, limit float64) {
+		if limit <= 0 {
+			return
+		}
+		ratio := current / limit
+		usage = append(usage, StreamUsage{
+			Stream:   info.Config.Name,
+			Resource: resource,
+			Current:  current,
+			Limit:    limit,
+			Ratio:    ratio,
+			Level:    config.level(ratio),
+		})
+	}
+
+	rolling := info.Config.Retention == jetstream.LimitsPolicy
+	if !rolling || info.Config.Discard == jetstream.DiscardNew {
+		add(UsageMessages, float64(info.State.Msgs), float64(info.Config.MaxMsgs))
+		add(UsageBytes, float64(info.State.Bytes), float64(info.Config.MaxBytes))
+	}
+
+	if !rolling {
+		var age time.Duration
+		if info.State.Msgs > 0 && !info.State.FirstTime.IsZero() {
+			age = time.Since(info.State.FirstTime)
+		}
+		add(UsageAge, age.Seconds(), info.Config.MaxAge.Seconds())
+	}
+
+	return usage
+}
+
+// checkUsage 檢查用量並在級別變化時告警，返回達到告警閾值的用量和無法讀取的 stream
+func (m *jetStreamNatsManager) checkUsage(ctx context.Context) ([]StreamUsage, []*StreamError) {
+	usage, failed := m.streamsUsage(ctx)
+
+	var degraded []StreamUsage
+	for _, u := range usage {
+		if u.Level != UsageOK {
+			degraded = append(degraded, u)
+		}
+		if previous, changed := m.usage.transition(u); changed {
+			m.alertUsage(ctx, UsageAlert{StreamUsage: u, Previous: previous, Time: time.Now()})
+		}
+	}
+	return degraded, failed
+}
+
+func (m *jetStreamNatsManager) alertUsage(ctx context.Context, alert UsageAlert) {
+	fields := []zap.Field{
+		zap.String("stream", alert.Stream),
+		zap.String("resource", string(alert.Resource)),
+		zap.String("level", string(alert.Level)),
+		zap.String("previous", string(alert.Previous)),
+		zap.Float64("current", alert.Current),
+		zap.Float64("limit", alert.Limit),
+		zap.Float64("usage_percentage", alert.Ratio*100),
+	}
+	switch alert.Level {
+	case UsageCritical:
+		m.logger.Error("stream usage critical", fields...)
+	case UsageWarning:
+		m.logger.Warn("stream usage high", fields...)
+	default:
+		m.logger.Info("stream usage recovered", fields...)
+	}
+
+	// 使用核心 NATS 發布，stream 已滿時告警仍能送達
+	if subject := m.config.Usage.AlertSubject; subject != "" {
+		if data, err := json.Marshal(alert); err != nil {
+			m.logger.Error("failed to encode usage alert", zap.Error(err))
+		} else if err = m.nc.Publish(subject, data); err != nil {
+			m.logger.Error("failed to publish usage alert",
+				zap.Error(err),
+				zap.String("subject", subject))
+		}
+	}
+
+	for _, hook := range m.usage.snapshotHooks() {
+		hook(ctx, alert)
+	}
+}
+
+// watchUsage 按 Usage.Interval 定期檢查用量，直到 Close
+func (m *jetStreamNatsManager) watchUsage(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(m.ctx, managementTimeout)
+			_, failed := m.checkUsage(ctx)
+			for _, f := range failed {
+				if m.ctx.Err() != nil {
+					break
+				}
+				m.logger.Warn("failed to check stream usage",
+					zap.Error(f.Err),
+					zap.String("stream", f.Stream))
+			}
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestStreamUsageResources(t *testing.T) {
+	state := jetstream.StreamState{
+		Msgs:      10000,
+		Bytes:     512,
+		FirstTime: time.Now().Add(-24 * time.Hour),
+	}
+	config := UsageConfig{}.withDefaults()
+
+	tests := []struct {
+		name      string
+		retention jetstream.RetentionPolicy
+		discard   jetstream.DiscardPolicy
+		want      []UsageResource
+	}{
+		{"limits discard old", jetstream.LimitsPolicy, jetstream.DiscardOld, nil},
+		{"limits discard new", jetstream.LimitsPolicy, jetstream.DiscardNew, []UsageResource{UsageMessages, UsageBytes}},
+		{"workqueue", jetstream.WorkQueuePolicy, jetstream.DiscardOld, []UsageResource{UsageMessages, UsageBytes, UsageAge}},
+		{"interest", jetstream.InterestPolicy, jetstream.DiscardNew, []UsageResource{UsageMessages, UsageBytes, UsageAge}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &jetstream.StreamInfo{
+				Config: jetstream.StreamConfig{
+					Name:      "TEST",
+					Retention: tt.retention,
+					Discard:   tt.discard,
+					MaxMsgs:   10000,
+					MaxBytes:  1024,
+					MaxAge:    24 * time.Hour,
+				},
+				State: state,
+			}
+			usage := streamUsage(info, config)
+			if len(usage) != len(tt.want) {
+				t.Fatalf("streamUsage() = %v, want resources %v", usage, tt.want)
+			}
+			for i, u := range usage {
+				if u.Resource != tt.want[i] {
+					t.Errorf("usage[%d].Resource = %s, want %s", i, u.Resource, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUsageConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  UsageConfig
+		wantErr bool
+	}{
+		{"defaults", UsageConfig{}, false},
+		{"custom", UsageConfig{WarningThreshold: 0.5, CriticalThreshold: 0.8}, false},
+		{"critical at one", UsageConfig{WarningThreshold: 0.9, CriticalThreshold: 1}, false},
+		{"warning above default critical", UsageConfig{WarningThreshold: 0.99}, true},
+		{"warning equals critical", UsageConfig{WarningThreshold: 0.8, CriticalThreshold: 0.8}, true},
+		{"critical above one", UsageConfig{CriticalThreshold: 1.5}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.withDefaults().validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
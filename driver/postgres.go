@@ -3,12 +3,17 @@ package driver
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go.uber.org/zap"
+
+	"goflare.io/nexus/worker"
 )
 
 // PostgresPool is an interface that represents a connection pool to a driver.
@@ -76,10 +81,28 @@ type PostgresConfig struct {
 	SSLMode     string `yaml:"ssl_mode"`
 	SSLRootCert string `yaml:"ssl_root_cert"`
 	Cluster     string `yaml:"cluster"`
+
+	// Retry 定義啟動時連接數據庫的重試策略
+	Retry RetryConfig `yaml:"retry"`
 }
 
 type DB struct {
 	Pool PostgresPool
+
+	// Workers 用於分發 LISTEN 收到的通知，為 nil 時在第一次 Listen 時按 WorkerConfig 創建
+	Workers *worker.Pool
+
+	// WorkerConfig 是按需創建 Workers 的配置，與 Workers 都為 nil 時通知在監聽協程中直接處理
+	WorkerConfig *worker.Config
+
+	// workersMu 保護 Workers 的按需創建
+	workersMu sync.Mutex
+
+	// Logger 用於記錄監聽與鎖的狀態，為 nil 時不輸出日誌
+	Logger *zap.Logger
+
+	// retry 定義連接斷開後重新連接的退避策略
+	retry RetryConfig
 }
 
 var dbConn = &DB{}
@@ -89,6 +112,11 @@ const maxOpenDbConn = 10
 const maxDbLifetime = 5 * time.Minute
 
 func ConnectSQL(config PostgresConfig) (*DB, error) {
+	return ConnectSQLContext(context.Background(), config)
+}
+
+// ConnectSQLContext 創建連接池並在 ctx 的約束下測試連接
+func ConnectSQLContext(ctx context.Context, config PostgresConfig) (*DB, error) {
 	connStr := config.URL
 
 	if config.Username != "" && config.Password != "" {
@@ -123,22 +151,31 @@ func ConnectSQL(config PostgresConfig) (*DB, error) {
 	pgConfig.MaxConns = int32(maxOpenDbConn)
 	pgConfig.MaxConnLifetime = maxDbLifetime
 
-	pool, err := pgxpool.NewWithConfig(context.Background(), pgConfig)
+	pool, err := pgxpool.NewWithConfig(ctx, pgConfig)
 	if err != nil {
 		return nil, fmt.Errorf("創建連接池失敗 | failed to create connection pool: %w", err)
 	}
 
-	dbConn.Pool = pool
-
-	if err = testDB(pool); err != nil {
+	if err = testDB(ctx, pool); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("測試數據庫連接失敗 | failed to test database connection: %w", err)
 	}
 
+	dbConn.Pool = pool
+	dbConn.retry = config.Retry
+
 	return dbConn, nil
 }
 
-func testDB(p *pgxpool.Pool) error {
-	conn, err := p.Acquire(context.Background())
+func (db *DB) logger() *zap.Logger {
+	if db.Logger == nil {
+		return zap.NewNop()
+	}
+	return db.Logger
+}
+
+func testDB(ctx context.Context, p *pgxpool.Pool) error {
+	conn, err := p.Acquire(ctx)
 	if err != nil {
 		return fmt.Errorf("獲取連接失敗 | failed to acquire connection: %w", err)
 	}
@@ -4,4 +4,7 @@ type RedisConfig struct {
 	Address  string `yaml:"address"`
 	Password string `yaml:"password"`
 	DB       int    `yaml:"db"`
+
+	// Retry 定義啟動時連接 Redis 的重試策略
+	Retry RetryConfig `yaml:"retry"`
 }
@@ -0,0 +1,125 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RetryConfig 定義連接重試策略
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts"`    // 最大嘗試次數
+	InitialBackoff time.Duration `yaml:"initial_backoff"` // 首次重試前的等待時間
+	MaxBackoff     time.Duration `yaml:"max_backoff"`     // 單次等待時間上限
+	Jitter         float64       `yaml:"jitter"`          // 隨機抖動比例 (0 ~ 1)
+	Timeout        time.Duration `yaml:"timeout"`         // 所有嘗試的總超時時間
+}
+
+// DefaultRetryConfig 返回默認重試配置
+// 10 max attempts
+// 500ms initial backoff
+// 10s max backoff
+// 0.2 jitter
+// 2 minutes total timeout
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:    10,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Jitter:         0.2,
+		Timeout:        2 * time.Minute,
+	}
+}
+
+// withDefaults 以默認值填充未設置的字段
+func (c RetryConfig) withDefaults() RetryConfig {
+	def := DefaultRetryConfig()
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = def.MaxAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = def.InitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = def.MaxBackoff
+	}
+	if c.MaxBackoff < c.InitialBackoff {
+		c.MaxBackoff = c.InitialBackoff
+	}
+	if c.Jitter < 0 {
+		c.Jitter = 0
+	}
+	if c.Jitter > 1 {
+		c.Jitter = 1
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = def.Timeout
+	}
+	return c
+}
+
+// Backoff 返回第 attempt 次失敗後 (從 0 開始) 應等待的時間
+func (c RetryConfig) Backoff(attempt int) time.Duration {
+	c = c.withDefaults()
+
+	backoff := c.MaxBackoff
+	if attempt < 32 {
+		if d := c.InitialBackoff * time.Duration(1<<attempt); d > 0 && d < c.MaxBackoff {
+			backoff = d
+		}
+	}
+
+	if c.Jitter > 0 {
+		delta := float64(backoff) * c.Jitter
+		backoff = time.Duration(float64(backoff) - delta + rand.Float64()*2*delta)
+	}
+	return backoff
+}
+
+// Retry 按照重試策略執行 fn，直到成功、次數用盡或超時
+// component 用於日誌中標識正在連接的組件
+func Retry(ctx context.Context, config RetryConfig, logger *zap.Logger, component string, fn func(ctx context.Context) error) error {
+	config = config.withDefaults()
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	var lastErr error
+	for attempt := 0; attempt < config.MaxAttempts; attempt++ {
+		if lastErr = fn(ctx); lastErr == nil {
+			if attempt > 0 {
+				logger.Info("connection established after retry",
+					zap.String("component", component),
+					zap.Int("attempt", attempt+1))
+			}
+			return nil
+		}
+
+		if attempt == config.MaxAttempts-1 {
+			break
+		}
+
+		backoff := config.Backoff(attempt)
+		logger.Warn("connection attempt failed, retrying",
+			zap.Error(lastErr),
+			zap.String("component", component),
+			zap.Int("attempt", attempt+1),
+			zap.Int("max_attempts", config.MaxAttempts),
+			zap.Duration("backoff", backoff))
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: gave up after %d attempts: %w (last error: %v)", component, attempt+1, ctx.Err(), lastErr)
+		case <-time.After(backoff):
+		}
+	}
+
+	return fmt.Errorf("%s: failed after %d attempts: %w", component, config.MaxAttempts, lastErr)
+}
@@ -0,0 +1,161 @@
+package inbox
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nats-io/nats.go"
+
+	"go.uber.org/zap"
+
+	"goflare.io/nexus/driver"
+)
+
+// TableName 是 inbox 表的名稱
+const TableName = "nexus_inbox"
+
+// MigrationsTable 記錄 inbox 遷移的版本，與應用及其他包的遷移分開
+const MigrationsTable = "nexus_inbox_migrations"
+
+// ErrNoMessageID 表示消息既沒有 Nats-Msg-Id 也沒有 JetStream 元數據，無法去重
+var ErrNoMessageID = errors.New("inbox: message has no id")
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// MigrationSource 返回創建 inbox 表的遷移來源，可用於 migrate.NewWithSourceInstance
+// 數據庫 URL 必須使用 MigrationsTable 作為 x-migrations-table，否則會與其他遷移的版本衝突
+func MigrationSource() (source.Driver, error) {
+	return iofs.New(migrations, "migrations")
+}
+
+// NewMigrate 返回執行 inbox 遷移的 migrate.Migrate，版本記錄在 MigrationsTable
+// 調用方需要導入 databaseURL 對應的遷移驅動，如 github.com/golang-migrate/migrate/v4/database/pgx/v5
+func NewMigrate(databaseURL string) (*migrate.Migrate, error) {
+	src, err := MigrationSource()
+	if err != nil {
+		return nil, fmt.Errorf("inbox: failed to load migrations: %w", err)
+	}
+	dbURL, err := driver.WithMigrationsTable(databaseURL, MigrationsTable)
+	if err != nil {
+		return nil, fmt.Errorf("inbox: %w", err)
+	}
+	return migrate.NewWithSourceInstance("iofs", src, dbURL)
+}
+
+// TxHandler 在記錄消息的同一個事務中處理消息
+// 返回錯誤時事務回滾，消息不會被記錄為已處理
+type TxHandler func(ctx context.Context, tx driver.PostgresTx, msg *nats.Msg) error
+
+// Handler 將 TxHandler 包裝為冪等的 NatsHandler
+// consumer 用於區分不同的消費者，同一條消息可以被不同消費者各處理一次
+// 重複投遞的消息會被跳過並視為處理成功；無法去重的消息返回永久錯誤，不會重新投遞
+func Handler(pool driver.PostgresPool, consumer string, handler TxHandler, logger *zap.Logger) driver.NatsHandler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return func(ctx context.Context, msg *nats.Msg) error {
+		id, err := MessageID(msg)
+		if err != nil {
+			// 重新投遞也無法得到 ID，直接終止或轉發到死信
+			return driver.Permanent(err)
+		}
+
+		tx, err := pool.BeginTx(ctx, pgx.TxOptions{})
+		if err != nil {
+			return fmt.Errorf("inbox: failed to begin transaction: %w", err)
+		}
+		defer func() {
+			_ = tx.Rollback(context.WithoutCancel(ctx))
+		}()
+
+		tag, err := tx.Exec(ctx,
+			"INSERT INTO "+TableName+" (consumer, message_id, subject) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+			consumer, id, msg.Subject)
+		if err != nil {
+			return fmt.Errorf("inbox: failed to record message: %w", err)
+		}
+
+		if tag.RowsAffected() == 0 {
+			logger.Info("skipping duplicate message",
+				zap.String("consumer", consumer),
+				zap.String("message_id", id),
+				zap.String("subject", msg.Subject))
+			return nil
+		}
+
+		if err = handler(ctx, tx, msg); err != nil {
+			return err
+		}
+
+		if err = tx.Commit(ctx); err != nil {
+			return fmt.Errorf("inbox: failed to commit transaction: %w", err)
+		}
+		return nil
+	}
+}
+
+// MessageID 返回用於去重的消息 ID
+// 優先使用 Nats-Msg-Id 消息頭，否則使用 JetStream 的 stream 名稱和序列號
+func MessageID(msg *nats.Msg) (string, error) {
+	if id := msg.Header.Get(nats.MsgIdHdr); id != "" {
+		return id, nil
+	}
+
+	meta, err := msg.Metadata()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrNoMessageID, err)
+	}
+	return meta.Stream + ":" + strconv.FormatUint(meta.Sequence.Stream, 10), nil
+}
+
+// Prune 刪除處理時間早於 olderThan 的記錄，返回刪除的行數
+// 保留時間應大於 JetStream 的重複投遞窗口，否則過期後的重投無法被識別
+func Prune(ctx context.Context, pool driver.PostgresPool, olderThan time.Duration) (int64, error) {
+	tag, err := pool.Exec(ctx,
+		"DELETE FROM "+TableName+" WHERE processed_at < now() - $1::interval",
+		olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("inbox: failed to prune: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RunPruner 每隔 interval 刪除超過 retention 的記錄，直到 ctx 被取消
+func RunPruner(ctx context.Context, pool driver.PostgresPool, interval, retention time.Duration, logger *zap.Logger) error {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			n, err := Prune(ctx, pool, retention)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				logger.Error("failed to prune inbox", zap.Error(err))
+				continue
+			}
+			if n > 0 {
+				logger.Info("pruned inbox records", zap.Int64("deleted", n))
+			}
+		}
+	}
+}
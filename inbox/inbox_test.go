@@ -0,0 +1,30 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+
+	"goflare.io/nexus/driver"
+)
+
+func TestHandlerWithoutMessageIDIsPermanent(t *testing.T) {
+	called := false
+	h := Handler(nil, "test", func(context.Context, driver.PostgresTx, *nats.Msg) error {
+		called = true
+		return nil
+	}, nil)
+
+	err := h(context.Background(), &nats.Msg{Subject: "orders.created"})
+	if !errors.Is(err, ErrNoMessageID) {
+		t.Fatalf("error = %v, want ErrNoMessageID", err)
+	}
+	if !errors.Is(err, driver.ErrPermanent) {
+		t.Fatalf("error = %v, want permanent", err)
+	}
+	if called {
+		t.Fatal("handler called for message without id")
+	}
+}
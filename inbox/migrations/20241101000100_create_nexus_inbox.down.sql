@@ -0,0 +1 @@
+DROP TABLE IF EXISTS nexus_inbox;
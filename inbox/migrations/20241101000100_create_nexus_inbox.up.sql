@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS nexus_inbox
+(
+    consumer     TEXT        NOT NULL,
+    message_id   TEXT        NOT NULL,
+    subject      TEXT        NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (consumer, message_id)
+);
+
+CREATE INDEX IF NOT EXISTS nexus_inbox_processed_at_idx
+    ON nexus_inbox (processed_at);
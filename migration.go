@@ -1,5 +1,49 @@
 package nexus
 
+import (
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4"
+
+	"go.uber.org/zap"
+
+	"goflare.io/nexus/inbox"
+	"goflare.io/nexus/outbox"
+	"goflare.io/nexus/saga"
+)
+
 type MigrationConfig struct {
 	Path string `yaml:"path"`
+
+	// Packages 列出需要遷移的內置包，每個包使用獨立的版本表
+	Packages []string `yaml:"packages"`
+}
+
+// packageMigrations 是內置包的遷移構造函數
+var packageMigrations = map[string]func(databaseURL string) (*migrate.Migrate, error){
+	"outbox": outbox.NewMigrate,
+	"inbox":  inbox.NewMigrate,
+	"saga":   saga.NewMigrate,
+}
+
+// ProvidePackageMigrations 按 Migration.Packages 返回內置包的遷移，鍵為包名
+// 應用的遷移仍由 ProvideMigration 提供，兩者的版本分開記錄
+func ProvidePackageMigrations(c *Core) (map[string]*migrate.Migrate, error) {
+	migrations := make(map[string]*migrate.Migrate, len(c.config.Migration.Packages))
+	for _, name := range c.config.Migration.Packages {
+		newMigrate, ok := packageMigrations[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown migration package %q", name)
+		}
+
+		m, err := newMigrate(c.postgresURL())
+		if err != nil {
+			c.logger.Error("Failed to create package migration",
+				zap.Error(err),
+				zap.String("package", name))
+			return nil, fmt.Errorf("failed to create %s migration: %w", name, err)
+		}
+		migrations[name] = m
+	}
+	return migrations, nil
 }
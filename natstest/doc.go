@@ -0,0 +1,6 @@
+// Package natstest 為測試提供內嵌的 NATS 服務器，讓 NatsManager 可以在離線環境中做集成測試
+//
+// 內嵌服務器依賴 nats-server，測試需要使用 nats_embedded 標籤運行：
+//
+//	go test -tags nats_embedded ./...
+package natstest
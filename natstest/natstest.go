@@ -0,0 +1,67 @@
+//go:build nats_embedded
+
+package natstest
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap/zaptest"
+
+	"goflare.io/nexus/driver"
+	"goflare.io/nexus/worker"
+)
+
+// NewServer 啟動一個隨機端口、使用測試臨時目錄存儲的內嵌服務器，測試結束時自動關閉
+func NewServer(t testing.TB) *driver.EmbeddedServer {
+	t.Helper()
+
+	srv, err := driver.StartEmbeddedNats(driver.EmbeddedNatsConfig{
+		StoreDir: t.TempDir(),
+	}, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("failed to start embedded nats server: %v", err)
+	}
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+// NewConn 連接到 srv，測試結束時自動關閉連接
+func NewConn(t testing.TB, srv *driver.EmbeddedServer) *nats.Conn {
+	t.Helper()
+
+	nc, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatalf("failed to connect to embedded nats server: %v", err)
+	}
+	t.Cleanup(nc.Close)
+	return nc
+}
+
+// NewManager 啟動內嵌服務器並創建 NatsManager，測試結束時自動關閉
+func NewManager(t testing.TB, config driver.NatsConfig) driver.NatsManager {
+	t.Helper()
+
+	srv := NewServer(t)
+	logger := zaptest.NewLogger(t)
+
+	nc, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatalf("failed to connect to embedded nats server: %v", err)
+	}
+
+	pool, err := worker.NewPool(config.Worker, logger)
+	if err != nil {
+		nc.Close()
+		t.Fatalf("failed to create worker pool: %v", err)
+	}
+
+	config.URL = srv.ClientURL()
+	nm, err := driver.NewNatsManager(nc, config, pool, logger)
+	if err != nil {
+		nc.Close()
+		t.Fatalf("failed to create nats manager: %v", err)
+	}
+	t.Cleanup(func() { _ = nm.Close() })
+	return nm
+}
@@ -0,0 +1,289 @@
+//go:build nats_embedded
+
+package natstest_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"go.uber.org/zap"
+
+	"goflare.io/nexus/driver"
+	"goflare.io/nexus/natstest"
+	"goflare.io/nexus/worker"
+)
+
+func testConfig() driver.NatsConfig {
+	config := driver.DefaultConfig("TEST")
+	config.Subjects = []string{"test.>"}
+	config.NakDelay = 50 * time.Millisecond
+	return config
+}
+
+// eventually 在 timeout 內輪詢 cond，直到其返回 true
+func eventually(t *testing.T, timeout time.Duration, cond func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal(msg)
+}
+
+func streamMsgs(t *testing.T, nm driver.NatsManager, name string) uint64 {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := nm.JetStream().Stream(ctx, name)
+	if err != nil {
+		t.Fatalf("stream %s: %v", name, err)
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatalf("stream %s info: %v", name, err)
+	}
+	return info.State.Msgs
+}
+
+func publish(t *testing.T, nm driver.NatsManager, subject string) {
+	t.Helper()
+	if err := nm.Publish(context.Background(), subject, []byte("hello")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+}
+
+func TestAck(t *testing.T) {
+	nm := natstest.NewManager(t, testConfig())
+
+	var calls atomic.Int32
+	if _, err := nm.Subscribe("test.ack", func(context.Context, *nats.Msg) error {
+		calls.Add(1)
+		return nil
+	}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	publish(t, nm, "test.ack")
+
+	// workqueue 的消息確認後從 stream 中刪除
+	eventually(t, 5*time.Second, func() bool { return streamMsgs(t, nm, "TEST") == 0 }, "message was not acked")
+	time.Sleep(200 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("handler called %d times, want 1", n)
+	}
+}
+
+func TestNakRedelivers(t *testing.T) {
+	nm := natstest.NewManager(t, testConfig())
+
+	var calls atomic.Int32
+	if _, err := nm.Subscribe("test.nak", func(context.Context, *nats.Msg) error {
+		if calls.Add(1) == 1 {
+			return errors.New("transient")
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	publish(t, nm, "test.nak")
+
+	eventually(t, 5*time.Second, func() bool { return calls.Load() >= 2 }, "message was not redelivered after nak")
+	eventually(t, 5*time.Second, func() bool { return streamMsgs(t, nm, "TEST") == 0 }, "message was not acked after retry")
+}
+
+func TestTermStopsRedelivery(t *testing.T) {
+	nm := natstest.NewManager(t, testConfig())
+
+	var calls atomic.Int32
+	if _, err := nm.Subscribe("test.term", func(context.Context, *nats.Msg) error {
+		calls.Add(1)
+		return driver.Permanent(errors.New("bad payload"))
+	}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	publish(t, nm, "test.term")
+
+	eventually(t, 5*time.Second, func() bool { return streamMsgs(t, nm, "TEST") == 0 }, "message was not terminated")
+	time.Sleep(300 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("handler called %d times, want 1", n)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	config := testConfig()
+	config.DeadLetter.Enabled = true
+	nm := natstest.NewManager(t, config)
+
+	if _, err := nm.Subscribe("test.dlq", func(context.Context, *nats.Msg) error {
+		return driver.Permanent(errors.New("bad payload"))
+	}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	publish(t, nm, "test.dlq")
+
+	eventually(t, 5*time.Second, func() bool { return streamMsgs(t, nm, "TEST_DLQ") == 1 }, "message was not dead lettered")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := nm.JetStream().Stream(ctx, "TEST_DLQ")
+	if err != nil {
+		t.Fatalf("dlq stream: %v", err)
+	}
+	msg, err := stream.GetLastMsgForSubject(ctx, "TEST.DLQ.>")
+	if err != nil {
+		t.Fatalf("get dead letter: %v", err)
+	}
+	if got := msg.Header.Get(driver.DLQSubjectHeader); got != "test.dlq" {
+		t.Errorf("%s = %q, want test.dlq", driver.DLQSubjectHeader, got)
+	}
+	if got := msg.Header.Get(driver.DLQReasonHeader); got == "" {
+		t.Errorf("%s is empty", driver.DLQReasonHeader)
+	}
+}
+
+func TestDeadLetterOverlapRejected(t *testing.T) {
+	srv := natstest.NewServer(t)
+	nc := natstest.NewConn(t, srv)
+
+	config := driver.DefaultConfig("TEST")
+	config.Subjects = []string{"TEST.>"}
+	config.DeadLetter.Enabled = true
+
+	pool, err := worker.NewPool(config.Worker, zap.NewNop())
+	if err != nil {
+		t.Fatalf("worker pool: %v", err)
+	}
+	if _, err = driver.NewNatsManager(nc, config, pool, zap.NewNop()); !errors.Is(err, driver.ErrDeadLetterOverlap) {
+		t.Fatalf("NewNatsManager error = %v, want ErrDeadLetterOverlap", err)
+	}
+}
+
+func TestHealthCheckSecondaryStreamUnavailable(t *testing.T) {
+	config := testConfig()
+	config.Streams = []driver.StreamConfig{{Name: "AUDIT", Subjects: []string{"audit.>"}}}
+	nm := natstest.NewManager(t, config)
+
+	if err := nm.HealthCheck(); err != nil {
+		t.Fatalf("HealthCheck() = %v, want nil", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := nm.JetStream().DeleteStream(ctx, "AUDIT"); err != nil {
+		t.Fatalf("delete stream: %v", err)
+	}
+
+	err := nm.HealthCheck()
+	var degraded *driver.StreamDegradedError
+	if !errors.As(err, &degraded) {
+		t.Fatalf("HealthCheck() = %v, want StreamDegradedError", err)
+	}
+	if len(degraded.Unavailable) != 1 || degraded.Unavailable[0].Stream != "AUDIT" {
+		t.Errorf("Unavailable = %v, want AUDIT", degraded.Unavailable)
+	}
+
+	if err = nm.JetStream().DeleteStream(ctx, "TEST"); err != nil {
+		t.Fatalf("delete stream: %v", err)
+	}
+	if err = nm.HealthCheck(); err == nil || errors.Is(err, driver.ErrStreamDegraded) {
+		t.Errorf("HealthCheck() = %v, want primary stream failure", err)
+	}
+}
+
+func TestScheduleNotStarvedByPendingMessages(t *testing.T) {
+	config := testConfig()
+	config.Schedule.Enabled = true
+	nm := natstest.NewManager(t, config)
+
+	arrived := make(chan time.Time, 1)
+	if _, err := nm.Subscribe("test.soon", func(context.Context, *nats.Msg) error {
+		select {
+		case arrived <- time.Now():
+		default:
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	// 超過默認的 MaxAckPending (1000) 的遠期消息
+	ctx := context.Background()
+	later := time.Now().Add(time.Hour)
+	for i := 0; i < 1100; i++ {
+		if err := nm.PublishAt(ctx, "test.later", []byte("later"), later); err != nil {
+			t.Fatalf("publish later: %v", err)
+		}
+	}
+	eventually(t, 10*time.Second, func() bool {
+		c, err := nm.JetStream().Consumer(ctx, "TEST_SCHEDULE", "TEST_SCHEDULE")
+		if err != nil {
+			return false
+		}
+		info, err := c.Info(ctx)
+		return err == nil && info.NumAckPending >= 1100
+	}, "far-future messages were not delivered to the scheduler")
+
+	due := time.Now().Add(500 * time.Millisecond)
+	if err := nm.PublishAt(ctx, "test.soon", []byte("soon"), due); err != nil {
+		t.Fatalf("publish soon: %v", err)
+	}
+
+	select {
+	case at := <-arrived:
+		if late := at.Sub(due); late > 2*time.Second {
+			t.Errorf("near-term message arrived %v late", late)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("near-term message was starved by pending far-future messages")
+	}
+}
+
+func TestScheduleOverlapRejected(t *testing.T) {
+	srv := natstest.NewServer(t)
+	nc := natstest.NewConn(t, srv)
+
+	config := driver.DefaultConfig("TEST")
+	config.Subjects = []string{"TEST.>"}
+	config.Schedule.Enabled = true
+
+	pool, err := worker.NewPool(config.Worker, zap.NewNop())
+	if err != nil {
+		t.Fatalf("worker pool: %v", err)
+	}
+	if _, err = driver.NewNatsManager(nc, config, pool, zap.NewNop()); !errors.Is(err, driver.ErrScheduleOverlap) {
+		t.Fatalf("NewNatsManager error = %v, want ErrScheduleOverlap", err)
+	}
+}
+
+func TestPushAndPullDurablesOnSameSubject(t *testing.T) {
+	config := testConfig()
+	// workqueue 不允許多個消費者過濾同一主題
+	config.Retention = "limits"
+	nm := natstest.NewManager(t, config)
+
+	var push, pull atomic.Int32
+	if _, err := nm.SubscribeDurable("test.both", func(context.Context, *nats.Msg) error {
+		push.Add(1)
+		return nil
+	}); err != nil {
+		t.Fatalf("subscribe durable: %v", err)
+	}
+	if _, err := nm.PullSubscribe("test.both", func(context.Context, *nats.Msg) error {
+		pull.Add(1)
+		return nil
+	}); err != nil {
+		t.Fatalf("pull subscribe: %v", err)
+	}
+	publish(t, nm, "test.both")
+
+	eventually(t, 5*time.Second, func() bool { return push.Load() == 1 && pull.Load() == 1 },
+		"push and pull durables did not both receive the message")
+}
@@ -24,6 +24,7 @@ import (
 	"go.uber.org/zap"
 
 	"goflare.io/nexus/driver"
+	"goflare.io/nexus/worker"
 )
 
 type Mode string
@@ -56,6 +57,12 @@ type Core struct {
 	// natsConn is the NATS connection
 	natsConn *nats.Conn
 
+	// natsHealth tracks the NATS connection state
+	natsHealth *driver.NatsConnHealth
+
+	// natsServer is the embedded NATS server in local mode
+	natsServer *driver.EmbeddedServer
+
 	natsManager driver.NatsManager
 
 	// stripeClient is the Stripe client
@@ -87,13 +94,31 @@ func (c *Core) New() error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	ctx := context.Background()
+
 	switch c.config.Database {
 	case Postgres:
 		c.logger.Info("Using Postgres database")
-		c.db, err = driver.ConnectSQL(c.config.Postgres)
+		err = driver.Retry(ctx, c.config.Postgres.Retry, c.logger, "postgres", func(ctx context.Context) error {
+			c.db, err = driver.ConnectSQLContext(ctx, c.config.Postgres)
+			return err
+		})
 	case Cockroach:
 		c.logger.Info("Using Cockroach database")
-		c.db, err = driver.ConnectSQL(c.config.Cockroach)
+		err = driver.Retry(ctx, c.config.Cockroach.Retry, c.logger, "cockroach", func(ctx context.Context) error {
+			c.db, err = driver.ConnectSQLContext(ctx, c.config.Cockroach)
+			return err
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if c.db != nil {
+		c.db.Logger = c.logger
+		// 分發通知的 worker pool 在第一次 Listen 時創建
+		workers := worker.DefaultConfig()
+		c.db.WorkerConfig = &workers
 	}
 
 	if c.config.Redis.Address != "" {
@@ -103,16 +128,29 @@ func (c *Core) New() error {
 			Password: c.config.Redis.Password,
 			DB:       c.config.Redis.DB,
 		})
-		if err = c.redisClient.Ping(context.Background()).Err(); err != nil {
+		if err = driver.Retry(ctx, c.config.Redis.Retry, c.logger, "redis", func(ctx context.Context) error {
+			return c.redisClient.Ping(ctx).Err()
+		}); err != nil {
 			return fmt.Errorf("failed to connect to Redis: %w", err)
 		}
 	}
 
+	if c.config.Mode == ModeLocal && c.config.NATS.Embedded {
+		c.logger.Info("Using embedded NATS server")
+		if c.natsServer, err = driver.StartEmbeddedNats(c.config.NATS.EmbeddedServer, c.logger); err != nil {
+			return fmt.Errorf("failed to start embedded NATS server: %w", err)
+		}
+		c.config.NATS.URL = c.natsServer.ClientURL()
+	}
+
 	if c.config.NATS.URL != "" {
 		c.logger.Info("Using NATS")
 		c.logger.Info(c.config.NATS.URL)
-		c.natsConn, err = nats.Connect(c.config.NATS.URL)
-		if err != nil {
+		c.natsHealth = driver.NewNatsConnHealth()
+		if err = driver.Retry(ctx, c.config.NATS.Retry, c.logger, "nats", func(ctx context.Context) error {
+			c.natsConn, err = driver.ConnectNats(c.config.NATS, c.logger, c.natsHealth)
+			return err
+		}); err != nil {
 			return fmt.Errorf("failed to connect to NATS: %w", err)
 		}
 	}
@@ -131,6 +169,7 @@ func (c *Core) Shutdown() error {
 	c.logger.Info("Starting shutdown of all components")
 
 	if c.db != nil {
+		c.db.ReleaseWorkers()
 		c.db.Pool.Close()
 	}
 
@@ -144,6 +183,10 @@ func (c *Core) Shutdown() error {
 		c.natsConn.Close()
 	}
 
+	if c.natsServer != nil {
+		c.natsServer.Shutdown()
+	}
+
 	c.logger.Info("All components shut down")
 	return nil
 }
@@ -160,6 +203,10 @@ func ProvidePostgresPool(c *Core) driver.PostgresPool {
 	return c.db.Pool
 }
 
+func ProvideDB(c *Core) *driver.DB {
+	return c.db
+}
+
 func ProvideRedis(c *Core) *redis.Client {
 	return c.redisClient
 }
@@ -168,6 +215,10 @@ func ProvideNATSConn(c *Core) *nats.Conn {
 	return c.natsConn
 }
 
+func ProvideNATSHealth(c *Core) *driver.NatsConnHealth {
+	return c.natsHealth
+}
+
 func ProvideStripeClient(c *Core) *client.API {
 	return c.stripeClient
 }
@@ -198,8 +249,8 @@ func ProvideS3(c *Core) (*s3.S3, error) {
 	return s3.New(sess), nil
 }
 
-func ProvideMigration(c *Core) *migrate.Migrate {
-
+// postgresURL 按配置拼接遷移使用的數據庫 URL
+func (c *Core) postgresURL() string {
 	connStr := c.config.Postgres.URL
 
 	if c.config.Postgres.Username != "" && c.config.Postgres.Password != "" {
@@ -226,6 +277,13 @@ func ProvideMigration(c *Core) *migrate.Migrate {
 		connStr += fmt.Sprintf("?sslmode=%s", c.config.Postgres.SSLMode)
 	}
 
+	return connStr
+}
+
+func ProvideMigration(c *Core) *migrate.Migrate {
+
+	connStr := c.postgresURL()
+
 	m, err := migrate.New(
 		fmt.Sprintf("file://%s", c.config.Migration.Path),
 		connStr,
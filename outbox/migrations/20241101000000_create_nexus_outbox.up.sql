@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS nexus_outbox
+(
+    id              BIGSERIAL PRIMARY KEY,
+    subject         TEXT        NOT NULL,
+    payload         BYTEA       NOT NULL,
+    headers         JSONB       NOT NULL DEFAULT '{}',
+    attempts        INT         NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at         TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS nexus_outbox_pending_idx
+    ON nexus_outbox (next_attempt_at, id)
+    WHERE sent_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS nexus_outbox_sent_idx
+    ON nexus_outbox (sent_at)
+    WHERE sent_at IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE nexus_outbox
+    DROP COLUMN IF EXISTS msg_id;
@@ -0,0 +1,3 @@
+-- msg_id 是全局唯一的 JetStream 消息 ID，多個服務或數據庫發布到同一個 stream 時不會衝突
+ALTER TABLE nexus_outbox
+    ADD COLUMN IF NOT EXISTS msg_id TEXT NOT NULL DEFAULT gen_random_uuid()::text;
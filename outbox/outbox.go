@@ -0,0 +1,100 @@
+package outbox
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+
+	"github.com/nats-io/nats.go"
+
+	"goflare.io/nexus/driver"
+)
+
+// TableName 是 outbox 表的名稱
+const TableName = "nexus_outbox"
+
+// MigrationsTable 記錄 outbox 遷移的版本，與應用及其他包的遷移分開
+const MigrationsTable = "nexus_outbox_migrations"
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// MigrationSource 返回創建 outbox 表的遷移來源，可用於 migrate.NewWithSourceInstance
+// 數據庫 URL 必須使用 MigrationsTable 作為 x-migrations-table，否則會與其他遷移的版本衝突
+func MigrationSource() (source.Driver, error) {
+	return iofs.New(migrations, "migrations")
+}
+
+// NewMigrate 返回執行 outbox 遷移的 migrate.Migrate，版本記錄在 MigrationsTable
+// 調用方需要導入 databaseURL 對應的遷移驅動，如 github.com/golang-migrate/migrate/v4/database/pgx/v5
+func NewMigrate(databaseURL string) (*migrate.Migrate, error) {
+	src, err := MigrationSource()
+	if err != nil {
+		return nil, fmt.Errorf("outbox: failed to load migrations: %w", err)
+	}
+	dbURL, err := driver.WithMigrationsTable(databaseURL, MigrationsTable)
+	if err != nil {
+		return nil, fmt.Errorf("outbox: %w", err)
+	}
+	return migrate.NewWithSourceInstance("iofs", src, dbURL)
+}
+
+// Config 定義 outbox relay 的配置
+type Config struct {
+	BatchSize    int                `yaml:"batch_size"`    // 每次輪詢最多處理的消息數
+	PollInterval time.Duration      `yaml:"poll_interval"` // 輪詢間隔
+	Retention    time.Duration      `yaml:"retention"`     // 已發送消息的保留時間，0 表示不清理
+	Retry        driver.RetryConfig `yaml:"retry"`         // 發布失敗後的退避策略
+}
+
+// DefaultConfig 返回默認配置
+// 100 messages per batch
+// 1 second poll interval
+// 7 days retention of sent messages
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:    100,
+		PollInterval: time.Second,
+		Retention:    7 * 24 * time.Hour,
+		Retry: driver.RetryConfig{
+			InitialBackoff: time.Second,
+			MaxBackoff:     5 * time.Minute,
+			Jitter:         0.2,
+		},
+	}
+}
+
+// Enqueue 在調用方的事務中寫入一條待發布的消息
+// 消息會在事務提交後由 Relay 發布到 NATS，事務回滾時消息也會被丟棄
+func Enqueue(ctx context.Context, tx driver.PostgresTx, subject string, payload []byte, headers nats.Header) error {
+	if subject == "" {
+		return fmt.Errorf("outbox: empty subject")
+	}
+
+	if headers == nil {
+		headers = nats.Header{}
+	}
+
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return fmt.Errorf("outbox: failed to encode headers: %w", err)
+	}
+
+	if payload == nil {
+		payload = []byte{}
+	}
+
+	if _, err = tx.Exec(ctx,
+		"INSERT INTO "+TableName+" (subject, payload, headers) VALUES ($1, $2, $3)",
+		subject, payload, encoded); err != nil {
+		return fmt.Errorf("outbox: failed to enqueue message: %w", err)
+	}
+
+	return nil
+}
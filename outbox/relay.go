@@ -0,0 +1,249 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nats-io/nats.go"
+
+	"go.uber.org/zap"
+
+	"goflare.io/nexus/driver"
+	"goflare.io/nexus/worker"
+)
+
+// message 是 outbox 表中的一條待發布消息
+type message struct {
+	id       int64
+	msgID    string
+	subject  string
+	payload  []byte
+	headers  nats.Header
+	attempts int
+	err      error
+}
+
+// Relay 將 outbox 表中的消息發布到 NATS
+// 多個副本可以同時運行，行鎖 (FOR UPDATE SKIP LOCKED) 保證同一條消息不會被並發發布
+type Relay struct {
+	pool    driver.PostgresPool
+	nats    driver.NatsManager
+	workers *worker.Pool
+	config  Config
+	logger  *zap.Logger
+}
+
+// NewRelay 創建新的 outbox relay
+func NewRelay(
+	pool driver.PostgresPool,
+	nm driver.NatsManager,
+	workers *worker.Pool,
+	config Config,
+	logger *zap.Logger) *Relay {
+
+	def := DefaultConfig()
+	if config.BatchSize <= 0 {
+		config.BatchSize = def.BatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = def.PollInterval
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Relay{
+		pool:    pool,
+		nats:    nm,
+		workers: workers,
+		config:  config,
+		logger:  logger,
+	}
+}
+
+// Run 持續輪詢並發布消息，直到 ctx 被取消
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Time{}
+	for {
+		// 一批處理滿時立即處理下一批，避免積壓
+		for {
+			n, err := r.ProcessBatch(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				r.logger.Error("failed to process outbox batch", zap.Error(err))
+				break
+			}
+			if n < r.config.BatchSize {
+				break
+			}
+		}
+
+		if r.config.Retention > 0 && time.Since(lastCleanup) >= time.Hour {
+			if err := r.Cleanup(ctx); err != nil && ctx.Err() == nil {
+				r.logger.Error("failed to clean up outbox", zap.Error(err))
+			}
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessBatch 鎖定一批到期的消息並發布，返回處理的消息數
+func (r *Relay) ProcessBatch(ctx context.Context) (int, error) {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(context.WithoutCancel(ctx))
+	}()
+
+	messages, err := r.lockPending(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	r.publishAll(ctx, messages)
+
+	batch := &pgx.Batch{}
+	for _, msg := range messages {
+		if msg.err == nil {
+			batch.Queue("UPDATE "+TableName+" SET sent_at = now(), attempts = attempts + 1, last_error = NULL WHERE id = $1", msg.id)
+			continue
+		}
+
+		backoff := r.config.Retry.Backoff(msg.attempts)
+		r.logger.Warn("failed to publish outbox message",
+			zap.Error(msg.err),
+			zap.Int64("id", msg.id),
+			zap.String("subject", msg.subject),
+			zap.Int("attempt", msg.attempts+1),
+			zap.Duration("backoff", backoff))
+		batch.Queue("UPDATE "+TableName+" SET attempts = attempts + 1, last_error = $2, next_attempt_at = now() + $3 WHERE id = $1",
+			msg.id, msg.err.Error(), backoff)
+	}
+
+	if err = tx.SendBatch(ctx, batch).Close(); err != nil {
+		return 0, fmt.Errorf("failed to update outbox messages: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit outbox batch: %w", err)
+	}
+
+	return len(messages), nil
+}
+
+// Cleanup 刪除超過保留時間的已發送消息
+func (r *Relay) Cleanup(ctx context.Context) error {
+	tag, err := r.pool.Exec(ctx,
+		"DELETE FROM "+TableName+" WHERE sent_at IS NOT NULL AND sent_at < now() - $1::interval",
+		r.config.Retention)
+	if err != nil {
+		return fmt.Errorf("failed to delete sent outbox messages: %w", err)
+	}
+
+	if tag.RowsAffected() > 0 {
+		r.logger.Info("cleaned up sent outbox messages",
+			zap.Int64("deleted", tag.RowsAffected()))
+	}
+	return nil
+}
+
+func (r *Relay) lockPending(ctx context.Context, tx pgx.Tx) ([]*message, error) {
+	rows, err := tx.Query(ctx,
+		"SELECT id, msg_id, subject, payload, headers, attempts FROM "+TableName+
+			" WHERE sent_at IS NULL AND next_attempt_at <= now()"+
+			" ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED",
+		r.config.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*message
+	for rows.Next() {
+		var (
+			msg     message
+			headers []byte
+		)
+		if err = rows.Scan(&msg.id, &msg.msgID, &msg.subject, &msg.payload, &headers, &msg.attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		if err = json.Unmarshal(headers, &msg.headers); err != nil {
+			msg.err = fmt.Errorf("failed to decode headers: %w", err)
+		}
+		messages = append(messages, &msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pending outbox messages: %w", err)
+	}
+	return messages, nil
+}
+
+// publishAll 通過 worker pool 並發發布消息，並等待全部完成
+func (r *Relay) publishAll(ctx context.Context, messages []*message) {
+	var wg sync.WaitGroup
+	for _, msg := range messages {
+		if msg.err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		task := func() error {
+			defer wg.Done()
+			msg.err = r.publish(ctx, msg)
+			return msg.err
+		}
+
+		if r.workers == nil {
+			_ = task()
+			continue
+		}
+
+		if err := r.workers.Submit(ctx, task); err != nil {
+			wg.Done()
+			msg.err = fmt.Errorf("failed to submit to worker pool: %w", err)
+		}
+	}
+	wg.Wait()
+}
+
+func (r *Relay) publish(ctx context.Context, msg *message) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic while publishing: %v", p)
+		}
+	}()
+
+	headers := msg.headers
+	if headers == nil {
+		headers = nats.Header{}
+	}
+	// 使用寫入時生成的 msg_id 作為消息 ID，讓 JetStream 對重複發布去重
+	// 自增 ID 在不同數據庫間會重複，導致其他服務的消息在去重窗口內被丟棄
+	if headers.Get(nats.MsgIdHdr) == "" {
+		headers.Set(nats.MsgIdHdr, msg.msgID)
+	}
+
+	return r.nats.PublishWithHeaders(ctx, msg.subject, msg.payload, headers)
+}
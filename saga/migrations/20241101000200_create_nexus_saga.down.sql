@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS nexus_saga_step;
+DROP TABLE IF EXISTS nexus_saga;
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS nexus_saga
+(
+    id           TEXT PRIMARY KEY,
+    name         TEXT        NOT NULL,
+    status       TEXT        NOT NULL,
+    current_step INT         NOT NULL DEFAULT 0,
+    data         JSONB       NOT NULL DEFAULT 'null',
+    error        TEXT,
+    deadline     TIMESTAMPTZ,
+    locked_until TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS nexus_saga_active_idx
+    ON nexus_saga (locked_until, created_at)
+    WHERE status IN ('running', 'compensating');
+
+CREATE INDEX IF NOT EXISTS nexus_saga_name_status_idx
+    ON nexus_saga (name, status, created_at);
+
+CREATE TABLE IF NOT EXISTS nexus_saga_step
+(
+    saga_id     TEXT        NOT NULL REFERENCES nexus_saga (id) ON DELETE CASCADE,
+    step        INT         NOT NULL,
+    name        TEXT        NOT NULL,
+    status      TEXT        NOT NULL,
+    attempts    INT         NOT NULL DEFAULT 0,
+    error       TEXT,
+    started_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at TIMESTAMPTZ,
+    PRIMARY KEY (saga_id, step)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE nexus_saga
+    DROP COLUMN IF EXISTS lock_owner;
@@ -0,0 +1,3 @@
+-- lock_owner 標記當前持有鎖的接管，鎖過期被其他副本接管後，原副本的寫入會失敗
+ALTER TABLE nexus_saga
+    ADD COLUMN IF NOT EXISTS lock_owner TEXT;
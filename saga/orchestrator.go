@@ -0,0 +1,429 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nuid"
+
+	"go.uber.org/zap"
+
+	"goflare.io/nexus/driver"
+	"goflare.io/nexus/worker"
+)
+
+// claimBatchSize 是每次輪詢最多接管的 saga 數
+const claimBatchSize = 10
+
+// Orchestrator 按定義執行 saga，並將進度保存到 Postgres
+// 多個副本可以同時運行，每個 saga 同一時間只由一個副本執行；副本崩潰後其他副本在 LockTimeout 後接管
+type Orchestrator struct {
+	pool    driver.PostgresPool
+	store   store
+	nats    driver.NatsManager
+	workers *worker.Pool
+	config  Config
+	logger  *zap.Logger
+
+	mu          sync.RWMutex
+	definitions map[string]Definition
+
+	// wake 通知 Run 立即接管新創建的 saga
+	wake chan struct{}
+}
+
+// NewOrchestrator 創建新的 saga 編排器
+func NewOrchestrator(
+	pool driver.PostgresPool,
+	nm driver.NatsManager,
+	workers *worker.Pool,
+	config Config,
+	logger *zap.Logger) *Orchestrator {
+
+	def := DefaultConfig()
+	if config.PollInterval <= 0 {
+		config.PollInterval = def.PollInterval
+	}
+	if config.LockTimeout <= 0 {
+		config.LockTimeout = def.LockTimeout
+	}
+	if config.StepTimeout <= 0 {
+		config.StepTimeout = def.StepTimeout
+	}
+	if config.Retry.MaxAttempts <= 0 {
+		config.Retry.MaxAttempts = def.Retry.MaxAttempts
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Orchestrator{
+		pool:        pool,
+		store:       &pgStore{pool: pool, lockTimeout: config.LockTimeout},
+		nats:        nm,
+		workers:     workers,
+		config:      config,
+		logger:      logger,
+		definitions: make(map[string]Definition),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Register 註冊 saga 定義，同名的定義會被替換
+// 步驟的順序決定執行和補償的順序，已開始的 saga 按步驟序號恢復，修改定義時只應在末尾追加步驟
+func (o *Orchestrator) Register(defs ...Definition) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, def := range defs {
+		if err := def.validate(); err != nil {
+			return err
+		}
+		o.definitions[def.Name] = def
+	}
+	return nil
+}
+
+func (o *Orchestrator) definition(name string) (Definition, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	def, ok := o.definitions[name]
+	return def, ok
+}
+
+// Start 創建名為 name 的 saga 並返回其 ID，data 作為第一個步驟的輸入
+// saga 由 Run 異步執行
+func (o *Orchestrator) Start(ctx context.Context, name string, data any) (string, error) {
+	def, ok := o.definition(name)
+	if !ok {
+		return "", fmt.Errorf("saga %s is not registered", name)
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode saga data: %w", err)
+	}
+
+	var deadline *time.Time
+	if def.Timeout > 0 {
+		t := time.Now().Add(def.Timeout)
+		deadline = &t
+	}
+
+	id := nuid.Next()
+	if err = o.store.create(ctx, &record{
+		id:       id,
+		name:     name,
+		status:   StatusRunning,
+		data:     encoded,
+		deadline: deadline,
+	}); err != nil {
+		return "", err
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+
+	o.logger.Info("saga started",
+		zap.String("saga", name),
+		zap.String("id", id))
+	return id, nil
+}
+
+// Run 持續接管並執行未完成的 saga，直到 ctx 被取消
+// ctx 被取消時正在執行的步驟會被中斷，saga 在鎖過期後由其他副本或下次啟動時恢復
+func (o *Orchestrator) Run(ctx context.Context) error {
+	ticker := time.NewTicker(o.config.PollInterval)
+	defer ticker.Stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		for {
+			claims, err := o.store.claim(ctx, claimBatchSize)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				o.logger.Error("failed to claim sagas", zap.Error(err))
+				break
+			}
+
+			for _, c := range claims {
+				wg.Add(1)
+				task := func() error {
+					defer wg.Done()
+					return o.execute(ctx, c)
+				}
+
+				if o.workers == nil {
+					go func() { _ = task() }()
+					continue
+				}
+				if err = o.workers.Submit(ctx, task); err != nil {
+					wg.Done()
+					o.logger.Error("failed to submit saga to worker pool",
+						zap.Error(err),
+						zap.String("id", c.id))
+				}
+			}
+
+			if len(claims) < claimBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// execute 從保存的進度繼續執行 saga
+// 鎖被其他副本接管時返回 ErrLockLost，已經開始的步驟由接管的副本重新執行
+func (o *Orchestrator) execute(ctx context.Context, c claimed) error {
+	rec, err := o.store.load(ctx, c.id)
+	if err != nil {
+		o.logger.Error("failed to load saga", zap.Error(err), zap.String("id", c.id))
+		return err
+	}
+	rec.owner = c.owner
+
+	err = o.resume(ctx, rec)
+	if errors.Is(err, ErrLockLost) {
+		o.logger.Warn("saga lock lost, another replica took over",
+			zap.String("saga", rec.name),
+			zap.String("id", rec.id))
+	}
+	return err
+}
+
+func (o *Orchestrator) resume(ctx context.Context, rec *record) error {
+	id := rec.id
+
+	def, ok := o.definition(rec.name)
+	if !ok {
+		// 保持鎖定，交給註冊了該定義的副本處理
+		err := fmt.Errorf("saga %s is not registered", rec.name)
+		o.logger.Warn("skipping saga", zap.Error(err), zap.String("id", id))
+		return err
+	}
+
+	if rec.currentStep > len(def.Steps) {
+		err := fmt.Errorf("saga %s is at step %d but only %d steps are defined", rec.name, rec.currentStep, len(def.Steps))
+		o.logger.Error("skipping saga", zap.Error(err), zap.String("id", id))
+		return err
+	}
+
+	if rec.status == StatusRunning {
+		if err := o.runForward(ctx, def, rec); err != nil {
+			return err
+		}
+	}
+	if rec.status == StatusCompensating {
+		return o.compensate(ctx, def, rec)
+	}
+	return nil
+}
+
+// runForward 按順序執行剩餘的步驟，失敗或超時時將 saga 轉為補償狀態
+// 失敗的步驟返回 ErrPermanent 時只補償之前的步驟；其他錯誤 (超時、傳輸錯誤) 無法確定步驟是否已在處理方生效，失敗的步驟也會被補償
+func (o *Orchestrator) runForward(ctx context.Context, def Definition, rec *record) error {
+	for rec.currentStep < len(def.Steps) {
+		index := rec.currentStep
+		step := def.Steps[index]
+
+		stepCtx, cancel := ctx, context.CancelFunc(func() {})
+		if rec.deadline != nil {
+			stepCtx, cancel = context.WithDeadline(ctx, *rec.deadline)
+		}
+		data, attempts, err := o.call(stepCtx, rec, step, false)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, errStore) {
+				return o.storeFailed(def, rec, step, err)
+			}
+			if errors.Is(err, context.DeadlineExceeded) && rec.deadline != nil && time.Now().After(*rec.deadline) {
+				err = fmt.Errorf("saga timed out during step %s: %w", step.Name, err)
+			}
+
+			o.logger.Warn("saga step failed, compensating",
+				zap.Error(err),
+				zap.String("saga", def.Name),
+				zap.String("id", rec.id),
+				zap.String("step", step.Name))
+			if saveErr := o.store.saveStep(ctx, rec, index, step.Name, StepFailed, attempts, err); saveErr != nil {
+				return saveErr
+			}
+
+			if !errors.Is(err, driver.ErrPermanent) {
+				rec.currentStep = index + 1
+			}
+			rec.status = StatusCompensating
+			rec.err = err.Error()
+			return o.store.save(ctx, rec)
+		}
+
+		if err = o.store.saveStep(ctx, rec, index, step.Name, StepCompleted, attempts, nil); err != nil {
+			return err
+		}
+		if len(data) > 0 {
+			rec.data = data
+		}
+		rec.currentStep++
+		if err = o.store.save(ctx, rec); err != nil {
+			return err
+		}
+	}
+
+	rec.status = StatusCompleted
+	if err := o.store.save(ctx, rec); err != nil {
+		return err
+	}
+
+	o.logger.Info("saga completed",
+		zap.String("saga", def.Name),
+		zap.String("id", rec.id))
+	return nil
+}
+
+// compensate 按相反順序補償已完成的步驟
+// 補償失敗時 saga 進入 failed 狀態，需要人工處理
+func (o *Orchestrator) compensate(ctx context.Context, def Definition, rec *record) error {
+	for rec.currentStep > 0 {
+		index := rec.currentStep - 1
+		step := def.Steps[index]
+
+		if step.Compensation != "" {
+			_, attempts, err := o.call(ctx, rec, step, true)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if errors.Is(err, errStore) {
+					return o.storeFailed(def, rec, step, err)
+				}
+
+				o.logger.Error("saga compensation failed",
+					zap.Error(err),
+					zap.String("saga", def.Name),
+					zap.String("id", rec.id),
+					zap.String("step", step.Name))
+				if saveErr := o.store.saveStep(ctx, rec, index, step.Name, StepCompensationFailed, attempts, err); saveErr != nil {
+					return saveErr
+				}
+
+				rec.status = StatusFailed
+				rec.err = fmt.Sprintf("compensation of step %s failed: %v (original error: %s)", step.Name, err, rec.err)
+				return o.store.save(ctx, rec)
+			}
+
+			if err = o.store.saveStep(ctx, rec, index, step.Name, StepCompensated, attempts, nil); err != nil {
+				return err
+			}
+		}
+
+		rec.currentStep--
+		if err := o.store.save(ctx, rec); err != nil {
+			return err
+		}
+	}
+
+	rec.status = StatusCompensated
+	if err := o.store.save(ctx, rec); err != nil {
+		return err
+	}
+
+	o.logger.Info("saga compensated",
+		zap.String("saga", def.Name),
+		zap.String("id", rec.id),
+		zap.String("error", rec.err))
+	return nil
+}
+
+// storeFailed 記錄 call 中的存儲錯誤，不改變 saga 狀態，鎖被接管時返回 ErrLockLost
+func (o *Orchestrator) storeFailed(def Definition, rec *record, step Step, err error) error {
+	if errors.Is(err, ErrLockLost) {
+		return ErrLockLost
+	}
+	o.logger.Error("failed to extend saga lock, retrying after it expires",
+		zap.Error(err),
+		zap.String("saga", def.Name),
+		zap.String("id", rec.id),
+		zap.String("step", step.Name))
+	return err
+}
+
+// call 發送步驟或補償命令，失敗時按重試策略重試，返回處理方更新後的數據和嘗試次數
+// 延長鎖失敗時返回包裝 errStore 的錯誤
+func (o *Orchestrator) call(ctx context.Context, rec *record, step Step, compensate bool) (json.RawMessage, int, error) {
+	subject := step.Action
+	if compensate {
+		subject = step.Compensation
+	}
+
+	maxAttempts := step.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = o.config.Retry.MaxAttempts
+	}
+	timeout := step.Timeout
+	if timeout <= 0 {
+		timeout = o.config.StepTimeout
+	}
+
+	req := StepRequest{
+		SagaID:     rec.id,
+		Saga:       rec.name,
+		Step:       step.Name,
+		Compensate: compensate,
+		Data:       rec.data,
+	}
+
+	for attempt := 0; ; attempt++ {
+		// 每次嘗試前延長鎖，避免長時間的重試被其他副本接管
+		if err := o.store.touch(ctx, rec.id, rec.owner); err != nil {
+			return nil, attempt, fmt.Errorf("%w: %w", errStore, err)
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		var resp StepResponse
+		err := o.nats.Request(callCtx, subject, req, &resp)
+		cancel()
+		if err == nil {
+			return resp.Data, attempt + 1, nil
+		}
+
+		if errors.Is(err, driver.ErrPermanent) || attempt+1 >= maxAttempts || ctx.Err() != nil {
+			return nil, attempt + 1, err
+		}
+
+		backoff := o.config.Retry.Backoff(attempt)
+		o.logger.Warn("saga step request failed, retrying",
+			zap.Error(err),
+			zap.String("id", rec.id),
+			zap.String("subject", subject),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("backoff", backoff))
+
+		select {
+		case <-ctx.Done():
+			return nil, attempt + 1, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
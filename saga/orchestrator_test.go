@@ -0,0 +1,430 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+
+	"goflare.io/nexus/driver"
+)
+
+// memStore 是內存中的 store，鎖的語義與 pgStore 相同
+type memStore struct {
+	mu          sync.Mutex
+	lockTimeout time.Duration
+	claims      int
+	order       []string
+	sagas       map[string]*memSaga
+	steps       map[string]map[int]StepStatus
+
+	// touchErr 不為 nil 時下一次 touch 返回該錯誤
+	touchErr error
+}
+
+type memSaga struct {
+	rec         record
+	owner       string
+	lockedUntil time.Time
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		lockTimeout: time.Minute,
+		sagas:       make(map[string]*memSaga),
+		steps:       make(map[string]map[int]StepStatus),
+	}
+}
+
+func (s *memStore) create(_ context.Context, rec *record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sagas[rec.id] = &memSaga{rec: *rec}
+	s.steps[rec.id] = make(map[int]StepStatus)
+	s.order = append(s.order, rec.id)
+	return nil
+}
+
+func (s *memStore) claim(_ context.Context, limit int) ([]claimed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.claims++
+	owner := fmt.Sprintf("owner-%d", s.claims)
+	now := time.Now()
+
+	var claims []claimed
+	for _, id := range s.order {
+		if len(claims) >= limit {
+			break
+		}
+		saga := s.sagas[id]
+		active := saga.rec.status == StatusRunning || saga.rec.status == StatusCompensating
+		if !active || saga.lockedUntil.After(now) {
+			continue
+		}
+		saga.owner = owner
+		saga.lockedUntil = now.Add(s.lockTimeout)
+		claims = append(claims, claimed{id: id, owner: owner})
+	}
+	return claims, nil
+}
+
+func (s *memStore) touch(_ context.Context, id, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.touchErr; err != nil {
+		s.touchErr = nil
+		return err
+	}
+	saga := s.sagas[id]
+	if saga.owner != owner {
+		return ErrLockLost
+	}
+	saga.lockedUntil = time.Now().Add(s.lockTimeout)
+	return nil
+}
+
+func (s *memStore) load(_ context.Context, id string) (*record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saga, ok := s.sagas[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	rec := saga.rec
+	return &rec, nil
+}
+
+func (s *memStore) save(_ context.Context, rec *record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saga := s.sagas[rec.id]
+	if saga.owner != rec.owner {
+		return ErrLockLost
+	}
+	saga.rec = *rec
+	saga.rec.owner = ""
+	if rec.status != StatusRunning && rec.status != StatusCompensating {
+		saga.owner = ""
+		saga.lockedUntil = time.Time{}
+	}
+	return nil
+}
+
+func (s *memStore) saveStep(_ context.Context, rec *record, index int, _ string, status StepStatus, _ int, _ error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sagas[rec.id].owner != rec.owner {
+		return ErrLockLost
+	}
+	s.steps[rec.id][index] = status
+	return nil
+}
+
+// expire 讓 saga 的鎖立即過期，模擬副本停頓超過 LockTimeout
+func (s *memStore) expire(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sagas[id].lockedUntil = time.Time{}
+}
+
+func (s *memStore) state(id string) (record, map[int]StepStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sagas[id].rec, maps.Clone(s.steps[id])
+}
+
+type stepFunc func(ctx context.Context, req StepRequest) (json.RawMessage, error)
+
+// fakeNats 將步驟請求直接分發給註冊的函數
+type fakeNats struct {
+	driver.NatsManager
+
+	mu       sync.Mutex
+	handlers map[string]stepFunc
+	calls    []string
+}
+
+func newFakeNats() *fakeNats {
+	return &fakeNats{handlers: make(map[string]stepFunc)}
+}
+
+func (f *fakeNats) handle(subject string, fn stepFunc) {
+	f.handlers[subject] = fn
+}
+
+func (f *fakeNats) Request(ctx context.Context, subject string, req, resp any) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, subject)
+	fn, ok := f.handlers[subject]
+	f.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no handler for %s", subject)
+	}
+
+	data, err := fn(ctx, req.(StepRequest))
+	if err != nil {
+		return err
+	}
+	resp.(*StepResponse).Data = data
+	return nil
+}
+
+func (f *fakeNats) called() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// appendStep 將步驟名追加到 saga 數據中
+func appendStep(name string) stepFunc {
+	return func(_ context.Context, req StepRequest) (json.RawMessage, error) {
+		var data []string
+		if err := json.Unmarshal(req.Data, &data); err != nil {
+			return nil, err
+		}
+		return json.Marshal(append(data, name))
+	}
+}
+
+func ok(context.Context, StepRequest) (json.RawMessage, error) { return nil, nil }
+
+func newTestOrchestrator(t *testing.T, st store, nm driver.NatsManager, defs ...Definition) *Orchestrator {
+	t.Helper()
+	o := NewOrchestrator(nil, nm, nil, Config{StepTimeout: time.Second}, zaptest.NewLogger(t))
+	o.store = st
+	if err := o.Register(defs...); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	return o
+}
+
+// runOnce 接管並同步執行所有待執行的 saga
+func runOnce(t *testing.T, o *Orchestrator) {
+	t.Helper()
+	claims, err := o.store.claim(context.Background(), claimBatchSize)
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	for _, c := range claims {
+		_ = o.execute(context.Background(), c)
+	}
+}
+
+var orderSaga = Definition{
+	Name: "order",
+	Steps: []Step{
+		{Name: "reserve", Action: "stock.reserve", Compensation: "stock.release", MaxAttempts: 1},
+		{Name: "charge", Action: "payment.charge", Compensation: "payment.refund", MaxAttempts: 1},
+	},
+}
+
+func TestOrchestratorForward(t *testing.T) {
+	st, nm := newMemStore(), newFakeNats()
+	nm.handle("stock.reserve", appendStep("reserve"))
+	nm.handle("payment.charge", appendStep("charge"))
+	o := newTestOrchestrator(t, st, nm, orderSaga)
+
+	id, err := o.Start(context.Background(), "order", []string{})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	runOnce(t, o)
+
+	rec, steps := st.state(id)
+	if rec.status != StatusCompleted {
+		t.Fatalf("status = %s, want %s (error %q)", rec.status, StatusCompleted, rec.err)
+	}
+	if got := string(rec.data); got != `["reserve","charge"]` {
+		t.Errorf("data = %s", got)
+	}
+	if steps[0] != StepCompleted || steps[1] != StepCompleted {
+		t.Errorf("steps = %v", steps)
+	}
+	if want := []string{"stock.reserve", "payment.charge"}; !slices.Equal(nm.called(), want) {
+		t.Errorf("calls = %v, want %v", nm.called(), want)
+	}
+}
+
+func TestOrchestratorPermanentFailureCompensatesPreviousSteps(t *testing.T) {
+	st, nm := newMemStore(), newFakeNats()
+	nm.handle("stock.reserve", ok)
+	nm.handle("stock.release", ok)
+	nm.handle("payment.charge", func(context.Context, StepRequest) (json.RawMessage, error) {
+		return nil, driver.Permanent(errors.New("card declined"))
+	})
+	nm.handle("payment.refund", ok)
+	o := newTestOrchestrator(t, st, nm, orderSaga)
+
+	id, err := o.Start(context.Background(), "order", nil)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	runOnce(t, o)
+
+	rec, steps := st.state(id)
+	if rec.status != StatusCompensated {
+		t.Fatalf("status = %s, want %s", rec.status, StatusCompensated)
+	}
+	if steps[0] != StepCompensated || steps[1] != StepFailed {
+		t.Errorf("steps = %v", steps)
+	}
+	// 永久失敗的步驟沒有生效，不需要補償
+	if want := []string{"stock.reserve", "payment.charge", "stock.release"}; !slices.Equal(nm.called(), want) {
+		t.Errorf("calls = %v, want %v", nm.called(), want)
+	}
+}
+
+func TestOrchestratorTimeoutCompensatesFailedStep(t *testing.T) {
+	st, nm := newMemStore(), newFakeNats()
+	nm.handle("stock.reserve", ok)
+	nm.handle("stock.release", ok)
+	nm.handle("payment.charge", func(ctx context.Context, _ StepRequest) (json.RawMessage, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	nm.handle("payment.refund", ok)
+
+	def := orderSaga
+	def.Steps = slices.Clone(orderSaga.Steps)
+	def.Steps[1].Timeout = 20 * time.Millisecond
+	o := newTestOrchestrator(t, st, nm, def)
+
+	id, err := o.Start(context.Background(), "order", nil)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	runOnce(t, o)
+
+	rec, steps := st.state(id)
+	if rec.status != StatusCompensated {
+		t.Fatalf("status = %s, want %s", rec.status, StatusCompensated)
+	}
+	if !strings.Contains(rec.err, context.DeadlineExceeded.Error()) {
+		t.Errorf("error = %q, want deadline exceeded", rec.err)
+	}
+	if steps[0] != StepCompensated || steps[1] != StepCompensated {
+		t.Errorf("steps = %v", steps)
+	}
+	// 超時的步驟可能已在處理方生效，按相反順序一併補償
+	want := []string{"stock.reserve", "payment.charge", "payment.refund", "stock.release"}
+	if !slices.Equal(nm.called(), want) {
+		t.Errorf("calls = %v, want %v", nm.called(), want)
+	}
+}
+
+func TestOrchestratorCompensationFailureMarksFailed(t *testing.T) {
+	st, nm := newMemStore(), newFakeNats()
+	nm.handle("stock.reserve", ok)
+	nm.handle("stock.release", func(context.Context, StepRequest) (json.RawMessage, error) {
+		return nil, driver.Permanent(errors.New("already shipped"))
+	})
+	nm.handle("payment.charge", func(context.Context, StepRequest) (json.RawMessage, error) {
+		return nil, driver.Permanent(errors.New("card declined"))
+	})
+	o := newTestOrchestrator(t, st, nm, orderSaga)
+
+	id, err := o.Start(context.Background(), "order", nil)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	runOnce(t, o)
+
+	rec, steps := st.state(id)
+	if rec.status != StatusFailed {
+		t.Fatalf("status = %s, want %s", rec.status, StatusFailed)
+	}
+	if steps[0] != StepCompensationFailed {
+		t.Errorf("steps = %v", steps)
+	}
+}
+
+func TestOrchestratorReclaimStopsStaleReplica(t *testing.T) {
+	st := newMemStore()
+	stale, fresh := newFakeNats(), newFakeNats()
+	a := newTestOrchestrator(t, st, stale, orderSaga)
+	b := newTestOrchestrator(t, st, fresh, orderSaga)
+
+	id, err := a.Start(context.Background(), "order", []string{})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	// a 執行第一步時停頓超過 LockTimeout，b 接管了 saga
+	var reclaimed []claimed
+	stale.handle("stock.reserve", func(ctx context.Context, req StepRequest) (json.RawMessage, error) {
+		st.expire(id)
+		if reclaimed, err = b.store.claim(ctx, claimBatchSize); err != nil {
+			return nil, err
+		}
+		return appendStep("stale")(ctx, req)
+	})
+	fresh.handle("stock.reserve", appendStep("reserve"))
+	fresh.handle("payment.charge", appendStep("charge"))
+
+	claims, err := a.store.claim(context.Background(), claimBatchSize)
+	if err != nil || len(claims) != 1 {
+		t.Fatalf("claim = %v, %v", claims, err)
+	}
+	if err = a.execute(context.Background(), claims[0]); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("stale execute error = %v, want ErrLockLost", err)
+	}
+	if len(reclaimed) != 1 {
+		t.Fatalf("reclaimed = %v", reclaimed)
+	}
+
+	// 原副本的寫入被拒絕，進度仍停在第一步之前
+	if rec, _ := st.state(id); rec.currentStep != 0 || string(rec.data) != "[]" {
+		t.Fatalf("stale replica overwrote state: step %d data %s", rec.currentStep, rec.data)
+	}
+
+	if err = b.execute(context.Background(), reclaimed[0]); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	rec, _ := st.state(id)
+	if rec.status != StatusCompleted || string(rec.data) != `["reserve","charge"]` {
+		t.Fatalf("status = %s data = %s", rec.status, rec.data)
+	}
+}
+
+func TestOrchestratorTouchFailureKeepsState(t *testing.T) {
+	st, nm := newMemStore(), newFakeNats()
+	nm.handle("stock.reserve", appendStep("reserve"))
+	nm.handle("payment.charge", appendStep("charge"))
+	o := newTestOrchestrator(t, st, nm, orderSaga)
+
+	id, err := o.Start(context.Background(), "order", []string{})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	// 延長鎖時數據庫暫時不可用，步驟沒有發送
+	st.touchErr = errors.New("connection reset")
+	runOnce(t, o)
+
+	rec, steps := st.state(id)
+	if rec.status != StatusRunning || rec.currentStep != 0 || len(steps) != 0 {
+		t.Fatalf("status = %s step = %d steps = %v, want untouched running saga", rec.status, rec.currentStep, steps)
+	}
+	if calls := nm.called(); len(calls) != 0 {
+		t.Fatalf("calls = %v, want none", calls)
+	}
+
+	// 鎖過期後重新執行
+	st.expire(id)
+	runOnce(t, o)
+
+	rec, _ = st.state(id)
+	if rec.status != StatusCompleted || string(rec.data) != `["reserve","charge"]` {
+		t.Fatalf("status = %s data = %s", rec.status, rec.data)
+	}
+}
@@ -0,0 +1,183 @@
+package saga
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+
+	"github.com/nats-io/nats.go"
+
+	"goflare.io/nexus/driver"
+)
+
+// 表名
+const (
+	TableName     = "nexus_saga"
+	StepTableName = "nexus_saga_step"
+)
+
+// MigrationsTable 記錄 saga 遷移的版本，與應用及其他包的遷移分開
+const MigrationsTable = "nexus_saga_migrations"
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// MigrationSource 返回創建 saga 表的遷移來源，可用於 migrate.NewWithSourceInstance
+// 數據庫 URL 必須使用 MigrationsTable 作為 x-migrations-table，否則會與其他遷移的版本衝突
+func MigrationSource() (source.Driver, error) {
+	return iofs.New(migrations, "migrations")
+}
+
+// NewMigrate 返回執行 saga 遷移的 migrate.Migrate，版本記錄在 MigrationsTable
+// 調用方需要導入 databaseURL 對應的遷移驅動，如 github.com/golang-migrate/migrate/v4/database/pgx/v5
+func NewMigrate(databaseURL string) (*migrate.Migrate, error) {
+	src, err := MigrationSource()
+	if err != nil {
+		return nil, fmt.Errorf("saga: failed to load migrations: %w", err)
+	}
+	dbURL, err := driver.WithMigrationsTable(databaseURL, MigrationsTable)
+	if err != nil {
+		return nil, fmt.Errorf("saga: %w", err)
+	}
+	return migrate.NewWithSourceInstance("iofs", src, dbURL)
+}
+
+// Status 是 saga 的狀態
+type Status string
+
+const (
+	StatusRunning      Status = "running"      // 正在按順序執行步驟
+	StatusCompleted    Status = "completed"    // 所有步驟執行成功
+	StatusCompensating Status = "compensating" // 某個步驟失敗或超時，正在按相反順序補償
+	StatusCompensated  Status = "compensated"  // 補償完成
+	StatusFailed       Status = "failed"       // 補償失敗，需要人工處理
+)
+
+// StepStatus 是單個步驟的狀態
+type StepStatus string
+
+const (
+	StepCompleted          StepStatus = "completed"
+	StepFailed             StepStatus = "failed"
+	StepCompensated        StepStatus = "compensated"
+	StepCompensationFailed StepStatus = "compensation_failed"
+)
+
+// ErrNotFound 表示 saga 不存在
+var ErrNotFound = errors.New("saga not found")
+
+// ErrLockLost 表示 saga 的鎖已過期並被其他副本接管，當前副本停止執行
+var ErrLockLost = errors.New("saga lock lost")
+
+// errStore 包裝 call 中延長鎖失敗的錯誤，與步驟請求的錯誤區分
+// 此時 saga 狀態保持不變，鎖過期後由任一副本重新執行
+var errStore = errors.New("saga store error")
+
+// Step 定義 saga 的一個步驟
+// Action 和 Compensation 是 NATS 請求主題，處理方使用 Handle 註冊
+type Step struct {
+	Name         string
+	Action       string        // 執行步驟的命令主題
+	Compensation string        // 撤銷步驟的命令主題，為空表示無需補償
+	Timeout      time.Duration // 單次請求的超時時間，默認使用 Config.StepTimeout
+	MaxAttempts  int           // 最大嘗試次數，默認使用 Config.Retry.MaxAttempts
+}
+
+// Definition 定義一個 saga
+type Definition struct {
+	Name    string
+	Steps   []Step
+	Timeout time.Duration // 從開始到完成所有步驟的時間上限，超過後開始補償，0 表示不限制
+}
+
+func (d Definition) validate() error {
+	if d.Name == "" {
+		return errors.New("saga name is required")
+	}
+	if len(d.Steps) == 0 {
+		return fmt.Errorf("saga %s has no steps", d.Name)
+	}
+	for i, step := range d.Steps {
+		if step.Name == "" || step.Action == "" {
+			return fmt.Errorf("saga %s step %d: name and action are required", d.Name, i)
+		}
+	}
+	return nil
+}
+
+// Config 定義 saga 編排器的配置
+type Config struct {
+	PollInterval time.Duration      `yaml:"poll_interval"` // 輪詢待恢復 saga 的間隔
+	LockTimeout  time.Duration      `yaml:"lock_timeout"`  // 執行中的 saga 被鎖定的時間，實例崩潰後其他實例在此之後接管
+	StepTimeout  time.Duration      `yaml:"step_timeout"`  // 步驟請求的默認超時時間
+	Retry        driver.RetryConfig `yaml:"retry"`         // 步驟失敗後的重試策略
+}
+
+// DefaultConfig 返回默認配置
+// 1 second poll interval
+// 1 minute lock timeout
+// 10 seconds step timeout
+// 3 attempts per step
+func DefaultConfig() Config {
+	return Config{
+		PollInterval: time.Second,
+		LockTimeout:  time.Minute,
+		StepTimeout:  10 * time.Second,
+		Retry: driver.RetryConfig{
+			MaxAttempts:    3,
+			InitialBackoff: 500 * time.Millisecond,
+			MaxBackoff:     10 * time.Second,
+			Jitter:         0.2,
+		},
+	}
+}
+
+// StepRequest 是發送給步驟處理方的命令
+type StepRequest struct {
+	SagaID     string          `json:"saga_id"`
+	Saga       string          `json:"saga"`
+	Step       string          `json:"step"`
+	Compensate bool            `json:"compensate"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// StepResponse 是步驟處理方的響應，Data 為空時保留原有的 saga 數據
+type StepResponse struct {
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// StepHandler 處理一個步驟，返回更新後的 saga 數據
+// 返回包裝了 driver.ErrPermanent 的錯誤時不再重試，直接開始補償
+type StepHandler[T any] func(ctx context.Context, req StepRequest, data T) (T, error)
+
+// Handle 在 subject 上處理 saga 步驟命令，用於實現 Step 的 Action 或 Compensation
+// 處理方必須是冪等的：重試或編排器接管時同一步驟可能被執行多次
+// Action 超時或返回非永久錯誤時該步驟也會被補償，因此 Compensation 必須能處理 Action 未生效或只部分生效的情況
+func Handle[T any](nm driver.NatsManager, subject string, handler StepHandler[T]) (*nats.Subscription, error) {
+	return driver.HandleTypedRequest(nm, subject, func(ctx context.Context, req StepRequest) (StepResponse, error) {
+		var data T
+		if len(req.Data) > 0 {
+			if err := json.Unmarshal(req.Data, &data); err != nil {
+				return StepResponse{}, driver.Permanent(fmt.Errorf("failed to decode saga data: %w", err))
+			}
+		}
+
+		result, err := handler(ctx, req, data)
+		if err != nil {
+			return StepResponse{}, err
+		}
+
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return StepResponse{}, fmt.Errorf("failed to encode saga data: %w", err)
+		}
+		return StepResponse{Data: encoded}, nil
+	})
+}
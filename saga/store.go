@@ -0,0 +1,267 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nats-io/nuid"
+
+	"goflare.io/nexus/driver"
+)
+
+// record 是執行中的 saga 在數據庫中的狀態
+type record struct {
+	id          string
+	owner       string // 持有鎖的接管，見 store.claim
+	name        string
+	status      Status
+	currentStep int
+	data        json.RawMessage
+	err         string
+	deadline    *time.Time
+}
+
+// State 是 saga 的當前狀態，用於查詢和排查
+type State struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Status      Status          `json:"status"`
+	CurrentStep int             `json:"current_step"` // 執行中為下一個要執行的步驟，補償中為尚未補償的步驟數
+	Data        json.RawMessage `json:"data"`
+	Error       string          `json:"error,omitempty"`
+	Deadline    *time.Time      `json:"deadline,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Steps       []StepState     `json:"steps"`
+}
+
+// StepState 是單個步驟的執行結果
+type StepState struct {
+	Index      int        `json:"index"`
+	Name       string     `json:"name"`
+	Status     StepStatus `json:"status"`
+	Attempts   int        `json:"attempts"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Filter 定義 List 的查詢條件，空字段表示不過濾
+type Filter struct {
+	Name   string
+	Status Status
+	Limit  int // 默認 100
+}
+
+// store 保存 saga 的執行狀態
+// 寫入只在 record.owner 仍持有鎖時生效，鎖已被其他副本接管時返回 ErrLockLost
+type store interface {
+	// create 寫入新的 saga
+	create(ctx context.Context, rec *record) error
+
+	// claim 鎖定最多 limit 個未完成且未被其他副本執行的 saga，每次接管使用新的 owner
+	claim(ctx context.Context, limit int) ([]claimed, error)
+
+	// touch 延長 saga 的鎖
+	touch(ctx context.Context, id, owner string) error
+
+	load(ctx context.Context, id string) (*record, error)
+
+	// save 保存 saga 的進度，saga 結束時釋放鎖
+	save(ctx context.Context, rec *record) error
+
+	saveStep(ctx context.Context, rec *record, index int, name string, status StepStatus, attempts int, stepErr error) error
+}
+
+// claimed 是一次接管的結果
+type claimed struct {
+	id    string
+	owner string
+}
+
+// pgStore 是基於 Postgres 的 store
+type pgStore struct {
+	pool        driver.PostgresPool
+	lockTimeout time.Duration
+}
+
+func (s *pgStore) create(ctx context.Context, rec *record) error {
+	if _, err := s.pool.Exec(ctx,
+		"INSERT INTO "+TableName+" (id, name, status, data, deadline) VALUES ($1, $2, $3, $4, $5)",
+		rec.id, rec.name, rec.status, rec.data, rec.deadline); err != nil {
+		return fmt.Errorf("failed to create saga: %w", err)
+	}
+	return nil
+}
+
+func (s *pgStore) claim(ctx context.Context, limit int) ([]claimed, error) {
+	owner := nuid.Next()
+	rows, err := s.pool.Query(ctx,
+		"UPDATE "+TableName+" SET locked_until = now() + $1::interval, lock_owner = $5"+
+			" WHERE id IN (SELECT id FROM "+TableName+
+			" WHERE status IN ($2, $3) AND (locked_until IS NULL OR locked_until < now())"+
+			" ORDER BY created_at LIMIT $4 FOR UPDATE SKIP LOCKED)"+
+			" RETURNING id",
+		s.lockTimeout, StatusRunning, StatusCompensating, limit, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim sagas: %w", err)
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim sagas: %w", err)
+	}
+	claims := make([]claimed, len(ids))
+	for i, id := range ids {
+		claims[i] = claimed{id: id, owner: owner}
+	}
+	return claims, nil
+}
+
+func (s *pgStore) touch(ctx context.Context, id, owner string) error {
+	tag, err := s.pool.Exec(ctx,
+		"UPDATE "+TableName+" SET locked_until = now() + $2::interval WHERE id = $1 AND lock_owner = $3",
+		id, s.lockTimeout, owner)
+	if err != nil {
+		return fmt.Errorf("failed to extend saga lock: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (s *pgStore) load(ctx context.Context, id string) (*record, error) {
+	rec := &record{id: id}
+	err := s.pool.QueryRow(ctx,
+		"SELECT name, status, current_step, data, COALESCE(error, ''), deadline FROM "+TableName+" WHERE id = $1",
+		id).Scan(&rec.name, &rec.status, &rec.currentStep, &rec.data, &rec.err, &rec.deadline)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saga: %w", err)
+	}
+	return rec, nil
+}
+
+func (s *pgStore) save(ctx context.Context, rec *record) error {
+	var lockedUntil, lockOwner any
+	if rec.status == StatusRunning || rec.status == StatusCompensating {
+		lockedUntil = time.Now().Add(s.lockTimeout)
+		lockOwner = rec.owner
+	}
+
+	var errMsg *string
+	if rec.err != "" {
+		errMsg = &rec.err
+	}
+
+	tag, err := s.pool.Exec(ctx,
+		"UPDATE "+TableName+" SET status = $2, current_step = $3, data = $4, error = $5, locked_until = $6,"+
+			" lock_owner = $7, updated_at = now()"+
+			" WHERE id = $1 AND lock_owner = $8",
+		rec.id, rec.status, rec.currentStep, rec.data, errMsg, lockedUntil, lockOwner, rec.owner)
+	if err != nil {
+		return fmt.Errorf("failed to save saga: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (s *pgStore) saveStep(ctx context.Context, rec *record, index int, name string, status StepStatus, attempts int, stepErr error) error {
+	var errMsg *string
+	if stepErr != nil {
+		msg := stepErr.Error()
+		errMsg = &msg
+	}
+
+	tag, err := s.pool.Exec(ctx,
+		"INSERT INTO "+StepTableName+" (saga_id, step, name, status, attempts, error, finished_at)"+
+			" SELECT $1::text, $2::int, $3::text, $4::text, $5::int, $6::text, now()"+
+			" WHERE EXISTS (SELECT 1 FROM "+TableName+" WHERE id = $1 AND lock_owner = $7)"+
+			" ON CONFLICT (saga_id, step) DO UPDATE SET status = EXCLUDED.status,"+
+			" attempts = "+StepTableName+".attempts + EXCLUDED.attempts, error = EXCLUDED.error, finished_at = now()",
+		rec.id, index, name, status, attempts, errMsg, rec.owner)
+	if err != nil {
+		return fmt.Errorf("failed to save saga step: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Get 返回 saga 及其步驟的當前狀態，不存在時返回 ErrNotFound
+func (o *Orchestrator) Get(ctx context.Context, id string) (*State, error) {
+	state := &State{ID: id}
+	err := o.pool.QueryRow(ctx,
+		"SELECT name, status, current_step, data, COALESCE(error, ''), deadline, created_at, updated_at FROM "+TableName+
+			" WHERE id = $1", id).
+		Scan(&state.Name, &state.Status, &state.CurrentStep, &state.Data, &state.Error, &state.Deadline,
+			&state.CreatedAt, &state.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saga: %w", err)
+	}
+
+	rows, err := o.pool.Query(ctx,
+		"SELECT step, name, status, attempts, COALESCE(error, ''), started_at, finished_at FROM "+StepTableName+
+			" WHERE saga_id = $1 ORDER BY step", id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saga steps: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var step StepState
+		if err = rows.Scan(&step.Index, &step.Name, &step.Status, &step.Attempts, &step.Error,
+			&step.StartedAt, &step.FinishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan saga step: %w", err)
+		}
+		state.Steps = append(state.Steps, step)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read saga steps: %w", err)
+	}
+	return state, nil
+}
+
+// List 按創建時間倒序返回符合條件的 saga，不包含步驟詳情
+func (o *Orchestrator) List(ctx context.Context, filter Filter) ([]State, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+
+	rows, err := o.pool.Query(ctx,
+		"SELECT id, name, status, current_step, data, COALESCE(error, ''), deadline, created_at, updated_at FROM "+TableName+
+			" WHERE ($1 = '' OR name = $1) AND ($2 = '' OR status = $2)"+
+			" ORDER BY created_at DESC LIMIT $3",
+		filter.Name, string(filter.Status), filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sagas: %w", err)
+	}
+	defer rows.Close()
+
+	var states []State
+	for rows.Next() {
+		var state State
+		if err = rows.Scan(&state.ID, &state.Name, &state.Status, &state.CurrentStep, &state.Data, &state.Error,
+			&state.Deadline, &state.CreatedAt, &state.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan saga: %w", err)
+		}
+		states = append(states, state)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read sagas: %w", err)
+	}
+	return states, nil
+}